@@ -1,4 +1,3 @@
 package main
 
-var version = "0.0.52
 var version = "0.0.52"
@@ -0,0 +1,128 @@
+package cover
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gfile "github.com/snail007/gmc/util/file"
+)
+
+const coverCacheDir = ".gmct/cover-cache"
+
+// coverCache stores the coverage profile of each tested package, the profiles are valid only for the same
+// cover packages, so the cache is cleared when the cover packages changed.
+type coverCache struct {
+	dir string
+}
+
+func newCoverCache(dir, coverPkgs string) (c *coverCache, err error) {
+	c = &coverCache{dir: dir}
+	index := filepath.Join(dir, "coverpkg")
+	if b, e := ioutil.ReadFile(index); e == nil && string(b) == coverPkgs {
+		return
+	}
+	if err = os.RemoveAll(dir); err != nil {
+		return
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	err = ioutil.WriteFile(index, []byte(coverPkgs), 0644)
+	return
+}
+
+func (s *coverCache) file(importPath string) string {
+	return filepath.Join(s.dir, url.PathEscape(importPath)+".cover")
+}
+
+func (s *coverCache) has(importPath string) bool {
+	return gfile.Exists(s.file(importPath))
+}
+
+func (s *coverCache) save(importPath, profile string) (err error) {
+	b, err := ioutil.ReadFile(profile)
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(s.file(importPath), b, 0644)
+}
+
+func (s *coverCache) remove(importPath string) {
+	os.Remove(s.file(importPath))
+}
+
+// changedFiles returns the absolute paths of files changed since the merge base of ref and HEAD,
+// uncommitted and untracked files are included.
+func changedFiles(ref string) (files []string, err error) {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return
+	}
+	base, err := gitOutput("merge-base", ref, "HEAD")
+	if err != nil {
+		return
+	}
+	changed, err := gitOutput("diff", "--name-only", "--no-renames", base)
+	if err != nil {
+		return
+	}
+	untracked, err := gitOutput("ls-files", "--others", "--exclude-standard", "--full-name", root)
+	if err != nil {
+		return
+	}
+	for _, name := range strings.Split(changed+"\n"+untracked, "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+		}
+	}
+	return
+}
+
+// affectedPackages returns the import paths of packages affected by the changed files. A package is changed if
+// any file in its directory changed, all packages of a module are changed if its go.mod or go.sum changed.
+// A package is affected if it is changed or depends on an affected package, including the test imports.
+func affectedPackages(packages []*goPackage, files []string) (affected map[string]bool) {
+	changedDirs := map[string]bool{}
+	changedModules := map[string]bool{}
+	for _, f := range files {
+		switch filepath.Base(f) {
+		case "go.mod", "go.sum":
+			changedModules[filepath.Dir(f)] = true
+		default:
+			changedDirs[filepath.Dir(f)] = true
+		}
+	}
+	affected = map[string]bool{}
+	for _, p := range packages {
+		if changedDirs[p.Dir] || (p.Module != nil && changedModules[p.Module.Dir]) {
+			affected[p.ImportPath] = true
+		}
+	}
+	// Deps is the transitive dependencies, but the test imports are direct, so repeat until nothing is added.
+	for {
+		added := false
+		for _, p := range packages {
+			if affected[p.ImportPath] {
+				continue
+			}
+			for _, deps := range [][]string{p.Deps, p.TestImports, p.XTestImports} {
+				for _, dep := range deps {
+					if affected[dep] {
+						affected[p.ImportPath] = true
+						added = true
+						break
+					}
+				}
+				if affected[p.ImportPath] {
+					break
+				}
+			}
+		}
+		if !added {
+			return
+		}
+	}
+}
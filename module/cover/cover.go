@@ -12,12 +12,13 @@ import (
 	"github.com/snail007/gmct/util"
 	"github.com/spf13/cobra"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
 func init() {
@@ -28,17 +29,28 @@ func init() {
 			Aliases: nil,
 			RunE: func(c *cobra.Command, a []string) error {
 				srv := NewCover(Args{
-					Race:       util.Must(c.Flags().GetBool("race")).Bool(),
-					Verbose:    util.Must(c.Flags().GetBool("verbose")).Bool(),
-					Silent:     util.Must(c.Flags().GetBool("silent")).Bool(),
-					KeepResult: util.Must(c.Flags().GetBool("keep")).Bool(),
-					ForceCheck: util.Must(c.Flags().GetBool("force")).Bool(),
-					Ordered:    util.Must(c.Flags().GetBool("order")).Bool(),
-					Only:       util.Must(c.Flags().GetBool("only")).Bool(),
-					CoverPkg:   util.Must(c.Flags().GetString("coverpkg")).String(),
-					Timeout:    util.Must(c.Flags().GetString("timeout")).String(),
-					Debug:      util.Must(c.Flags().GetBool("debug")).Bool(),
+					Race:         util.Must(c.Flags().GetBool("race")).Bool(),
+					Verbose:      util.Must(c.Flags().GetBool("verbose")).Bool(),
+					Silent:       util.Must(c.Flags().GetBool("silent")).Bool(),
+					KeepResult:   util.Must(c.Flags().GetBool("keep")).Bool(),
+					ForceCheck:   util.Must(c.Flags().GetBool("force")).Bool(),
+					Ordered:      util.Must(c.Flags().GetBool("order")).Bool(),
+					Only:         util.Must(c.Flags().GetBool("only")).Bool(),
+					CoverPkg:     util.Must(c.Flags().GetString("coverpkg")).String(),
+					Timeout:      util.Must(c.Flags().GetString("timeout")).String(),
+					Debug:        util.Must(c.Flags().GetBool("debug")).Bool(),
+					Min:          util.Must(c.Flags().GetFloat64("min")).Float64(),
+					Config:       util.Must(c.Flags().GetString("config")).String(),
+					Format:       util.Must(c.Flags().GetString("format")).String(),
+					Output:       util.Must(c.Flags().GetString("output")).String(),
+					Diff:         util.Must(c.Flags().GetString("diff")).String(),
+					DiffMin:      util.Must(c.Flags().GetFloat64("diff-min")).Float64(),
+					Junit:        util.Must(c.Flags().GetString("junit")).String(),
+					ChangedSince: util.Must(c.Flags().GetString("changed-since")).String(),
+					Record:       util.Must(c.Flags().GetBool("record")).Bool(),
 				})
+				// errors after this, such as test fail, are not usage errors.
+				c.SilenceUsage = true
 				err := srv.init()
 				if err != nil {
 					return err
@@ -57,22 +69,41 @@ func init() {
 		cmd.Flags().String("coverpkg", "", "additional cover packages split by comma")
 		cmd.Flags().String("timeout", "15m", `timeout flag accept any input valid for time.ParseDuration.A duration string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as "300ms", "1.5h" or "2h45m". Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`)
 		cmd.Flags().Bool("debug", false, "in debug mode will logging steps of testing")
+		cmd.Flags().Float64("min", 0, "minimum total coverage percent, exit with non-zero code if the coverage is below it")
+		cmd.Flags().String("format", "", "export the coverage in format: cobertura, lcov, json or html")
+		cmd.Flags().String("output", "", "the file to export the coverage to, default: coverage.xml, coverage.lcov, coverage.json or coverage.html by --format")
+		cmd.Flags().String("diff", "", "report the coverage of lines changed since the git ref, such as: origin/main")
+		cmd.Flags().Float64("diff-min", 0, "minimum diff coverage percent, exit with non-zero code if the diff coverage is below it")
+		cmd.Flags().String("junit", "", "write the test results to the file in JUnit XML format, such as: report.xml")
+		cmd.Flags().String("changed-since", "", "only test the packages affected by the changes since the git ref, the coverage of others is loaded from the cache in "+coverCacheDir)
+		cmd.Flags().Bool("record", false, "append the coverage of packages to the history file "+defaultHistoryFile+", see: gmct cover history")
+		cmd.Flags().StringP("config", "c", defaultConfigFile, "config file of coverage thresholds, such as per package minimum coverage")
+		cmd.AddCommand(historyCommand())
 		root.AddCommand(cmd)
 	})
 }
 
 type Args struct {
-	Race       bool
-	Verbose    bool
-	Silent     bool
-	KeepResult bool
-	ForceCheck bool
-	Ordered    bool
-	Only       bool
-	CoverPkg   string
-	Timeout    string
-	Debug      bool
-	Workers    int
+	Race         bool
+	Verbose      bool
+	Silent       bool
+	KeepResult   bool
+	ForceCheck   bool
+	Ordered      bool
+	Only         bool
+	CoverPkg     string
+	Timeout      string
+	Debug        bool
+	Workers      int
+	Min          float64
+	Config       string
+	Format       string
+	Output       string
+	Diff         string
+	DiffMin      float64
+	Junit        string
+	ChangedSince string
+	Record       bool
 }
 
 type Cover struct {
@@ -85,45 +116,21 @@ func NewCover(args Args) *Cover {
 
 func (s *Cover) init() (err error) {
 	s.args.Workers = gcond.Cond(s.args.Workers <= 0, runtime.NumCPU(), s.args.Workers).Int()
+	if _, ok := defaultOutputs[s.args.Format]; s.args.Format != "" && !ok {
+		return fmt.Errorf("unknown format: %s, should be one of: %s, %s, %s, %s", s.args.Format, formatCobertura, formatLcov, formatJSON, formatHTML)
+	}
 	return
 }
 
 func (s *Cover) Start() (err error) {
 	os.Setenv("GMCT_COVER", "true")
-	gopath := os.Getenv("GOPATH")
-	if gopath == "" {
-		return fmt.Errorf("environment variables $GOPATH not found")
-	}
-	gopath, _ = filepath.Abs(gopath)
-	pwd, _ := os.Getwd()
-	if !strings.HasPrefix(pwd, gopath) {
-		return fmt.Errorf("your current path must be in $GOPATH/src")
-	}
-	s.debugf("$GOPATH is " + gopath)
-	race := ""
-	if s.args.Race {
-		race = "-race"
-	}
-	verbose := ""
-	if s.args.Verbose {
-		verbose = "-v"
-	}
-	dir := "./..."
-	if s.args.Only {
-		dir = "./"
-	}
-	var output string
-	output, err = s.exec("go list "+dir+" | grep -v /main | grep -v /vendor | grep -v /examples | grep -v grep", "")
+	packagesAll, err := listPackages(s.args.Only)
 	if err != nil {
 		return
 	}
-	packagesAll := strings.Split(output, "\n")
-	packages := []string{}
+	packages := []*goPackage{}
 	for _, pkg := range packagesAll {
-		if len(pkg) == 0 {
-			continue
-		}
-		if s.args.ForceCheck || s.hasTestFile(pkg) {
+		if s.args.ForceCheck || pkg.hasTestFile() {
 			packages = append(packages, pkg)
 		}
 	}
@@ -131,9 +138,15 @@ func (s *Cover) Start() (err error) {
 		err = fmt.Errorf("no match package found")
 		return
 	}
-	s.debugf("testing packages list, %s", strings.Join(packages, ","))
+	var importPaths []string
+	for _, pkg := range packages {
+		importPaths = append(importPaths, pkg.ImportPath)
+	}
+	s.debugf("testing packages list, %s", strings.Join(importPaths, ","))
 	coverProfiles := make([]string, len(packages))
-	payload := "mode: atomic\n"
+	startTime := time.Now()
+	results := make([]*packageResult, len(packages))
+	outputLock := &sync.Mutex{}
 	var p *gpool.Pool
 	if !s.args.Ordered {
 		p = gpool.New(s.args.Workers)
@@ -144,7 +157,7 @@ func (s *Cover) Start() (err error) {
 	} else if s.args.Timeout != "" {
 		timeout = s.args.Timeout
 	}
-	coverPkgs := strings.Join(packages, ",")
+	coverPkgs := strings.Join(importPaths, ",")
 	addtionalCoverPkgs := strings.Split(s.args.CoverPkg, ",")
 	if len(addtionalCoverPkgs) > 0 {
 		for _, v := range addtionalCoverPkgs {
@@ -160,46 +173,88 @@ func (s *Cover) Start() (err error) {
 	os.Setenv("GMCT_COVER_PACKAGES", coverPkgs)
 	os.Setenv("GMCT_TEST_TIMEOUT", timeout)
 
+	var cache *coverCache
+	cached := map[string]bool{}
+	if s.args.ChangedSince != "" {
+		cache, err = newCoverCache(coverCacheDir, coverPkgs)
+		if err != nil {
+			return
+		}
+		var files []string
+		files, err = changedFiles(s.args.ChangedSince)
+		if err != nil {
+			return
+		}
+		affected := affectedPackages(packagesAll, files)
+		s.debugf("affected packages since %s, %v", s.args.ChangedSince, affected)
+		for _, pkg := range packages {
+			if !affected[pkg.ImportPath] && cache.has(pkg.ImportPath) {
+				cached[pkg.ImportPath] = true
+			}
+		}
+	}
+
 	for k, pkg := range packages {
+		if cached[pkg.ImportPath] {
+			fmt.Printf("ok  \t%s\t(cover cached)\n", pkg.ImportPath)
+			continue
+		}
 		b := make([]byte, 16)
 		io.ReadFull(rand.Reader, b)
 		coverProfiles[k] = filepath.Join(os.TempDir(), fmt.Sprintf("%x", b)) + ".gocc.tmp"
-		w := func(coverprofile, pkg string) {
-			workDir := filepath.Join(gopath, "src", pkg)
-			cmd := `go test -timeout ` + timeout + ` ` + verbose + ` ` + race +
-				` -covermode=atomic -coverprofile=` + coverprofile +
-				` -coverpkg=` + coverPkgs + ` ` + pkg
-			output, err = s.exec(cmd, workDir)
-			if err != nil {
-				return
+		w := func(k int, coverprofile string, pkg *goPackage) {
+			args := []string{"test", "-json", "-timeout", timeout}
+			if s.args.Race {
+				args = append(args, "-race")
 			}
-			for _, line := range strings.Split(output, "\n") {
-				if strings.Contains(line, "warning: no packages being tested depend on matches for pattern") {
-					continue
-				}
-				s := strings.Trim(strings.Split(line, " of statements in")[0], "\n")
-				if s != "" {
-					fmt.Println(s)
+			args = append(args, "-covermode=atomic", "-coverprofile="+coverprofile, "-coverpkg="+coverPkgs, pkg.ImportPath)
+			r := s.goTest(pkg, args)
+			results[k] = r
+			outputLock.Lock()
+			defer outputLock.Unlock()
+			if s.args.Verbose {
+				for _, line := range r.lines {
+					if line != "" && line != r.summary() && !strings.HasPrefix(line, "ok ") {
+						fmt.Println(strings.Split(line, " of statements in")[0])
+					}
 				}
 			}
+			fmt.Println(r.summary())
 		}
 		if !s.args.Ordered {
-			coverProfile, packageName := coverProfiles[k], pkg
+			idx, coverProfile, packageName := k, coverProfiles[k], pkg
 			p.Submit(func() {
-				w(coverProfile, packageName)
+				w(idx, coverProfile, packageName)
 			})
 		} else {
-			w(coverProfiles[k], pkg)
+			w(k, coverProfiles[k], pkg)
 		}
 	}
 	if !s.args.Ordered {
 		p.WaitDone()
 		p.Stop()
 	}
+	failed := printFailureSummary(results)
+	if cache != nil {
+		for k, r := range results {
+			if r == nil {
+				continue
+			}
+			if r.failed() {
+				cache.remove(r.importPath)
+			} else if err = cache.save(r.importPath, coverProfiles[k]); err != nil {
+				return
+			}
+		}
+	}
+	if s.args.Junit != "" {
+		if err = writeJunit(s.args.Junit, results, startTime); err != nil {
+			return
+		}
+	}
 	//scan additional code coverage coverProfiles by testing
 	for _, pkg := range packages {
-		path := filepath.Join(gopath, "src", pkg)
-		fs, _ := filepath.Glob(path + "/*.gocc.tmp")
+		fs, _ := filepath.Glob(pkg.Dir + "/*.gocc.tmp")
 		for _, f := range fs {
 			coverProfiles = append(coverProfiles, f)
 		}
@@ -207,32 +262,52 @@ func (s *Cover) Start() (err error) {
 			fmt.Printf("cover coverProfiles found %v\n", fs)
 		}
 	}
+	profile := newCoverProfile("atomic")
 	for _, file := range coverProfiles {
 		if _, err := os.Stat(file); os.IsNotExist(err) {
 			continue
 		}
-		p, err := ioutil.ReadFile(file)
+		err = profile.addFile(file)
+		os.Remove(file)
 		if err != nil {
-			return err
+			return
 		}
-		ps := strings.Split(string(p), "\n")
-		payload += strings.Join(ps[1:], "\n")
-		os.Remove(file)
 	}
-	err = ioutil.WriteFile("coverage.txt", []byte(payload), 0755)
-	if err != nil {
-		return
+	for pkg := range cached {
+		if err = profile.addFile(cache.file(pkg)); err != nil {
+			return
+		}
 	}
-	_, err = s.exec("go tool cover -func=coverage.txt -o total.txt", "")
+	err = profile.writeFile("coverage.txt")
 	if err != nil {
 		return
 	}
-	output, err = s.exec("tail -n 1 total.txt", "")
-	if err != nil {
-		return
+	fmt.Printf("total:\t\t\t(statements)\t%s\n", formatPercent(profile.total().Percent()))
+	if s.args.Record {
+		if err = appendHistory(defaultHistoryFile, profile); err != nil {
+			return
+		}
+	}
+	var e *exporter
+	if s.args.Format != "" || s.args.Diff != "" {
+		e, err = newExporter(profile, packagesAll)
+		if err != nil {
+			return
+		}
 	}
-	if output != "" {
-		fmt.Println(output)
+	if s.args.Format != "" {
+		if err = e.export(s.args.Format, s.args.Output); err != nil {
+			return
+		}
+	}
+	var diffPercent float64
+	if s.args.Diff != "" {
+		var changed map[string]map[int]bool
+		changed, err = changedLines(s.args.Diff)
+		if err != nil {
+			return
+		}
+		diffPercent = printDiffCoverage(s.args.Diff, diffCoverage(e, changed))
 	}
 	if !s.args.Silent {
 		_, err = s.exec("go tool cover -html=coverage.txt", "")
@@ -240,16 +315,64 @@ func (s *Cover) Start() (err error) {
 			return
 		}
 	}
-	err = os.Remove("total.txt")
-	if err != nil {
-		return
-	}
 	if !s.args.KeepResult {
 		err = os.Remove("coverage.txt")
 		if err != nil {
 			return
 		}
 	}
+	if err = s.checkGate(profile); err != nil {
+		return
+	}
+	if s.args.Diff != "" && diffPercent < s.args.DiffMin {
+		return fmt.Errorf("diff coverage %s is below the minimum %s", formatPercent(diffPercent), formatPercent(s.args.DiffMin))
+	}
+	if failed > 0 {
+		return fmt.Errorf("testing fail, %d of %d packages failed", failed, len(packages))
+	}
+	return
+}
+
+// goTest runs go test -json in the package directory, the failure of go test is kept in the result.
+func (s *Cover) goTest(pkg *goPackage, args []string) (r *packageResult) {
+	r = newPackageResult(pkg.ImportPath)
+	s.debugf("[WORK DIR]: %v, [COMMAND]: go %v", pkg.Dir, strings.Join(args, " "))
+	c := exec.Command("go", args...)
+	c.Env = append(c.Env, os.Environ()...)
+	c.Dir = pkg.Dir
+	stderr := &bytes.Buffer{}
+	c.Stderr = stderr
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		r.err = err
+		return
+	}
+	if err = c.Start(); err != nil {
+		r.err = err
+		return
+	}
+	parseErr := r.parse(stdout)
+	r.err = c.Wait()
+	if r.err == nil {
+		r.err = parseErr
+	}
+	r.stderr = stderr.String()
+	return
+}
+
+// checkGate checks the coverage thresholds, --min overrides the total minimum in config file.
+func (s *Cover) checkGate(profile *coverProfile) (err error) {
+	cfg, err := loadGateConfig(s.args.Config)
+	if err != nil {
+		return
+	}
+	if s.args.Min > 0 {
+		cfg.min = s.args.Min
+	}
+	if failures := cfg.check(profile); len(failures) > 0 {
+		printGateFailures(failures)
+		return fmt.Errorf("coverage check fail, %d below the minimum", len(failures))
+	}
 	return
 }
 func (s *Cover) debugf(formats string, values ...interface{}) {
@@ -284,11 +407,6 @@ func (s *Cover) exec(cmd, workDir string) (output string, err error) {
 	return
 }
 
-func (s *Cover) hasTestFile(pkg string) bool {
-	path := filepath.Join(os.Getenv("GOPATH"), "src", pkg)
-	files, _ := filepath.Glob(path + "/*_test.go")
-	return len(files) > 0
-}
 func (s *Cover) Stop() {
 	return
 }
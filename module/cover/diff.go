@@ -0,0 +1,154 @@
+package cover
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diffFile is the coverage of changed lines in a file.
+type diffFile struct {
+	file      string
+	covered   []int
+	uncovered []int
+}
+
+func (s *diffFile) total() int {
+	return len(s.covered) + len(s.uncovered)
+}
+
+func gitOutput(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s fail, error: %s, output: %s", strings.Join(args, " "), err, stderr.String())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// changedLines returns the added and modified lines of go files since the merge base of ref and HEAD,
+// uncommitted changes are included, the key is the absolute file path.
+func changedLines(ref string) (changed map[string]map[int]bool, err error) {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return
+	}
+	base, err := gitOutput("merge-base", ref, "HEAD")
+	if err != nil {
+		return
+	}
+	out, err := gitOutput("diff", "-U0", "--no-color", "--no-ext-diff", base, "--", "*.go")
+	if err != nil {
+		return
+	}
+	return parseChangedLines(root, out)
+}
+
+// parseChangedLines returns the added and modified lines in the output of git diff -U0, the deleted files and
+// the hunks of pure deletion have no lines, the key is the file path joined with root.
+func parseChangedLines(root, diff string) (changed map[string]map[int]bool, err error) {
+	changed = map[string]map[int]bool{}
+	var file string
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = ""
+			if name := strings.TrimPrefix(line, "+++ "); strings.HasPrefix(name, "b/") {
+				file = filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(name, "b/")))
+				changed[file] = map[int]bool{}
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			// @@ -a,b +c,d @@, d is 1 if omitted.
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			added := strings.SplitN(strings.TrimPrefix(fields[2], "+"), ",", 2)
+			start, _ := strconv.Atoi(added[0])
+			count := 1
+			if len(added) == 2 {
+				count, _ = strconv.Atoi(added[1])
+			}
+			for i := start; i < start+count; i++ {
+				changed[file][i] = true
+			}
+		}
+	}
+	return changed, scanner.Err()
+}
+
+// diffCoverage returns the coverage of changed lines, only the lines in the coverage profile are counted,
+// so the comments, blank lines and the files without coverage data are ignored.
+func diffCoverage(e *exporter, changed map[string]map[int]bool) (files []*diffFile) {
+	for _, file := range e.profile.fileNames() {
+		lines := changed[e.filePath(file)]
+		if len(lines) == 0 {
+			continue
+		}
+		f := &diffFile{file: e.relPath(file)}
+		executable, hits := e.lineHits(file)
+		for _, l := range executable {
+			if !lines[l] {
+				continue
+			}
+			if hits[l] > 0 {
+				f.covered = append(f.covered, l)
+			} else {
+				f.uncovered = append(f.uncovered, l)
+			}
+		}
+		if f.total() > 0 {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].file < files[j].file })
+	return
+}
+
+// lineRanges formats the sorted lines as ranges, such as: 3-5,9
+func lineRanges(lines []int) string {
+	var ranges []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+// printDiffCoverage prints the diff coverage of each file and returns the total percent.
+func printDiffCoverage(ref string, files []*diffFile) (percent float64) {
+	fmt.Printf("\ndiff coverage since %s:\n", ref)
+	if len(files) == 0 {
+		fmt.Println("no changed lines found in the coverage profile")
+		return 100
+	}
+	fmt.Printf("%-50s %8s %8s %8s  %s\n", "FILE", "COVERED", "CHANGED", "PERCENT", "UNCOVERED LINES")
+	var covered, total int
+	for _, f := range files {
+		fmt.Printf("%-50s %8d %8d %8s  %s\n", f.file, len(f.covered), f.total(),
+			formatPercent(float64(len(f.covered))*100/float64(f.total())), lineRanges(f.uncovered))
+		covered += len(f.covered)
+		total += f.total()
+	}
+	percent = float64(covered) * 100 / float64(total)
+	fmt.Printf("diff coverage: %s, %d of %d changed lines covered\n", formatPercent(percent), covered, total)
+	return
+}
@@ -0,0 +1,72 @@
+package cover
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChangedLines(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		name  string
+		diff  string
+		file  string
+		lines []int
+	}{
+		{
+			name: "count",
+			diff: "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -3,2 +3,3 @@ func a() {\n-x\n-y\n+x\n+y\n+z\n",
+			file: "a.go", lines: []int{3, 4, 5},
+		},
+		{
+			name: "omitted count",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -7 +8 @@\n-x\n+y\n@@ -20,0 +22 @@\n+z\n",
+			file: "a.go", lines: []int{8, 22},
+		},
+		{
+			name: "pure deletion",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -5,2 +4,0 @@\n-x\n-y\n",
+			file: "a.go", lines: nil,
+		},
+		{
+			name: "new file",
+			diff: "--- /dev/null\n+++ b/pkg/b.go\n@@ -0,0 +1,2 @@\n+package pkg\n+\n",
+			file: "pkg/b.go", lines: []int{1, 2},
+		},
+	} {
+		changed, err := parseChangedLines("/root", c.diff)
+		assert.NoError(err, c.name)
+		var lines []int
+		for l := 1; l < 100; l++ {
+			if changed[filepath.Join("/root", c.file)][l] {
+				lines = append(lines, l)
+			}
+		}
+		assert.Equal(c.lines, lines, c.name)
+	}
+	// the hunks of deleted file are skipped.
+	changed, err := parseChangedLines("/root", "--- a/c.go\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-package c\n-\n")
+	assert.NoError(err)
+	assert.Empty(changed)
+}
+
+func TestDiffCoverage(t *testing.T) {
+	assert := assert.New(t)
+	p := newCoverProfile("set")
+	assert.NoError(p.addLine("example.com/a/a.go:3.10,5.2 2 1"))
+	assert.NoError(p.addLine("example.com/a/a.go:7.10,9.2 2 0"))
+	assert.NoError(p.addLine("example.com/a/b.go:3.10,4.2 1 0"))
+	e := &exporter{profile: p, dirs: map[string]string{"example.com/a": "/src/a"}, root: "/src"}
+	files := diffCoverage(e, map[string]map[int]bool{
+		"/src/a/a.go": {1: true, 4: true, 8: true, 9: true},
+		"/src/a/c.go": {1: true},
+	})
+	assert.Len(files, 1)
+	assert.Equal("a/a.go", files[0].file)
+	assert.Equal([]int{4}, files[0].covered)
+	assert.Equal([]int{8, 9}, files[0].uncovered)
+	assert.Equal("8-9", lineRanges(files[0].uncovered))
+	assert.Equal("1,3-5,9", lineRanges([]int{1, 3, 4, 5, 9}))
+}
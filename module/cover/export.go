@@ -0,0 +1,298 @@
+package cover
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	formatCobertura = "cobertura"
+	formatLcov      = "lcov"
+	formatJSON      = "json"
+	formatHTML      = "html"
+)
+
+var defaultOutputs = map[string]string{
+	formatCobertura: "coverage.xml",
+	formatLcov:      "coverage.lcov",
+	formatJSON:      "coverage.json",
+	formatHTML:      "coverage.html",
+}
+
+// exporter writes the merged coverage profile in the other formats, dirs maps import path to the package directory,
+// which is used to get the source file paths.
+type exporter struct {
+	profile *coverProfile
+	dirs    map[string]string
+	root    string
+}
+
+func newExporter(profile *coverProfile, packages []*goPackage) (e *exporter, err error) {
+	e = &exporter{profile: profile, dirs: map[string]string{}}
+	e.root, _ = os.Getwd()
+	for _, p := range packages {
+		e.dirs[p.ImportPath] = p.Dir
+	}
+	// packages of --coverpkg may be not in the tested packages.
+	var missing []string
+	for pkg := range profile.packageStats() {
+		if _, ok := e.dirs[pkg]; !ok {
+			missing = append(missing, pkg)
+		}
+	}
+	if len(missing) > 0 {
+		err = goList(".", missing, func(d *json.Decoder) error {
+			p := &goPackage{}
+			if err := d.Decode(p); err != nil {
+				return err
+			}
+			e.dirs[p.ImportPath] = p.Dir
+			return nil
+		})
+	}
+	return
+}
+
+// filePath returns the absolute path of the file in profile, it is the file itself if the directory is unknown.
+func (s *exporter) filePath(file string) string {
+	if dir, ok := s.dirs[path.Dir(file)]; ok && dir != "" {
+		return filepath.Join(dir, path.Base(file))
+	}
+	return file
+}
+
+// relPath returns the file path relative to current directory if the file is in it.
+func (s *exporter) relPath(file string) string {
+	p := s.filePath(file)
+	if rel, err := filepath.Rel(s.root, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(p)
+}
+
+// lineHits returns the hit count of each line of the file, the maximum count of the blocks contain the line is used.
+func (s *exporter) lineHits(file string) (lines []int, hits map[int]int) {
+	hits = map[int]int{}
+	for _, b := range s.profile.fileBlocks(file) {
+		for l := b.StartLine; l <= b.EndLine; l++ {
+			if v, ok := hits[l]; !ok || b.Count > v {
+				hits[l] = b.Count
+			}
+		}
+	}
+	for l := range hits {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	return
+}
+
+func (s *exporter) export(format, output string) (err error) {
+	if output == "" {
+		output = defaultOutputs[format]
+	}
+	if format == formatHTML {
+		// coverage.txt is written before exporting.
+		return s.exportHTML("coverage.txt", output)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	switch format {
+	case formatCobertura:
+		err = s.exportCobertura(w)
+	case formatLcov:
+		err = s.exportLcov(w)
+	case formatJSON:
+		err = s.exportJSON(w)
+	}
+	if err != nil {
+		return
+	}
+	return w.Flush()
+}
+
+func (s *exporter) exportHTML(profileFile, output string) (err error) {
+	out, err := exec.Command("go", "tool", "cover", "-html="+profileFile, "-o", output).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go tool cover -html fail, error: %s, output: %s", err, string(out))
+	}
+	return
+}
+
+func (s *exporter) exportLcov(w io.Writer) (err error) {
+	for _, file := range s.profile.fileNames() {
+		lines, hits := s.lineHits(file)
+		fmt.Fprintf(w, "TN:\nSF:%s\n", s.filePath(file))
+		covered := 0
+		for _, l := range lines {
+			fmt.Fprintf(w, "DA:%d,%d\n", l, hits[l])
+			if hits[l] > 0 {
+				covered++
+			}
+		}
+		fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(lines), covered)
+	}
+	return
+}
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        float64            `xml:"line-rate,attr"`
+	BranchRate      float64            `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      float64            `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Complexity float64          `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	Filename   string          `xml:"filename,attr"`
+	LineRate   float64         `xml:"line-rate,attr"`
+	BranchRate float64         `xml:"branch-rate,attr"`
+	Complexity float64         `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+func lineRate(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+	return float64(covered) / float64(valid)
+}
+
+func (s *exporter) exportCobertura(w io.Writer) (err error) {
+	c := &coberturaCoverage{
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Sources:   []string{s.root},
+	}
+	pkgs := map[string]*coberturaPackage{}
+	pkgCounts := map[string][2]int{}
+	var pkgNames []string
+	for _, file := range s.profile.fileNames() {
+		pkgName := path.Dir(file)
+		p, ok := pkgs[pkgName]
+		if !ok {
+			p = &coberturaPackage{Name: pkgName}
+			pkgs[pkgName] = p
+			pkgNames = append(pkgNames, pkgName)
+		}
+		lines, hits := s.lineHits(file)
+		class := coberturaClass{Name: path.Base(file), Filename: s.relPath(file)}
+		covered := 0
+		for _, l := range lines {
+			class.Lines = append(class.Lines, coberturaLine{Number: l, Hits: hits[l]})
+			if hits[l] > 0 {
+				covered++
+			}
+		}
+		class.LineRate = lineRate(covered, len(lines))
+		p.Classes = append(p.Classes, class)
+		cnt := pkgCounts[pkgName]
+		pkgCounts[pkgName] = [2]int{cnt[0] + covered, cnt[1] + len(lines)}
+		c.LinesCovered += covered
+		c.LinesValid += len(lines)
+	}
+	for _, name := range pkgNames {
+		p := pkgs[name]
+		p.LineRate = lineRate(pkgCounts[name][0], pkgCounts[name][1])
+		c.Packages = append(c.Packages, *p)
+	}
+	c.LineRate = lineRate(c.LinesCovered, c.LinesValid)
+	io.WriteString(w, xml.Header)
+	io.WriteString(w, `<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`+"\n")
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err = enc.Encode(c); err != nil {
+		return
+	}
+	_, err = io.WriteString(w, "\n")
+	return
+}
+
+type jsonStat struct {
+	Statements int     `json:"statements"`
+	Covered    int     `json:"covered"`
+	Percent    float64 `json:"percent"`
+}
+
+type jsonFile struct {
+	jsonStat
+	File           string `json:"file"`
+	Path           string `json:"path"`
+	UncoveredLines []int  `json:"uncovered_lines"`
+}
+
+type jsonPackage struct {
+	jsonStat
+	Package string      `json:"package"`
+	Files   []*jsonFile `json:"files"`
+}
+
+type jsonReport struct {
+	Total    jsonStat       `json:"total"`
+	Packages []*jsonPackage `json:"packages"`
+}
+
+func newJSONStat(s coverStat) jsonStat {
+	return jsonStat{Statements: s.Statements, Covered: s.Covered, Percent: s.Percent()}
+}
+
+func (s *exporter) exportJSON(w io.Writer) (err error) {
+	r := &jsonReport{Total: newJSONStat(s.profile.total()), Packages: []*jsonPackage{}}
+	stats := s.profile.packageStats()
+	pkgs := map[string]*jsonPackage{}
+	for _, file := range s.profile.fileNames() {
+		pkgName := path.Dir(file)
+		p, ok := pkgs[pkgName]
+		if !ok {
+			p = &jsonPackage{jsonStat: newJSONStat(stats[pkgName]), Package: pkgName}
+			pkgs[pkgName] = p
+			r.Packages = append(r.Packages, p)
+		}
+		f := &jsonFile{jsonStat: newJSONStat(s.profile.fileStat(file)), File: file, Path: s.filePath(file), UncoveredLines: []int{}}
+		lines, hits := s.lineHits(file)
+		for _, l := range lines {
+			if hits[l] == 0 {
+				f.UncoveredLines = append(f.UncoveredLines, l)
+			}
+		}
+		p.Files = append(p.Files, f)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,117 @@
+package cover
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snail007/gmc"
+	gfile "github.com/snail007/gmc/util/file"
+)
+
+const defaultConfigFile = "gmccover.toml"
+
+// packageMin is the minimum coverage of packages, path ends with /... matches the package and all sub packages.
+type packageMin struct {
+	path string
+	min  float64
+}
+
+func (s *packageMin) match(pkg string) bool {
+	if strings.HasSuffix(s.path, "/...") {
+		prefix := strings.TrimSuffix(s.path, "/...")
+		return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+	}
+	return pkg == s.path
+}
+
+type gateConfig struct {
+	min      float64
+	packages []*packageMin
+}
+
+// loadGateConfig loads the coverage thresholds from the config file, it is optional if it is the default file.
+// config example:
+//
+//	# minimum total coverage percent, option --min overrides it
+//	min = 80
+//	[[package]]
+//	path = "github.com/foo/bar/..."
+//	min = 60
+func loadGateConfig(file string) (cfg *gateConfig, err error) {
+	cfg = &gateConfig{}
+	if file == "" || (file == defaultConfigFile && !gfile.Exists(file)) {
+		return
+	}
+	c, err := gmc.New.ConfigFile(file)
+	if err != nil {
+		return
+	}
+	cfg.min = c.GetFloat64("min")
+	var items []map[string]interface{}
+	switch v := c.Get("package").(type) {
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for _, m := range v {
+			if mm, ok := m.(map[string]interface{}); ok {
+				items = append(items, mm)
+			}
+		}
+	}
+	for _, m := range items {
+		pc := gmc.New.Config()
+		if err = pc.MergeConfigMap(m); err != nil {
+			return
+		}
+		p := &packageMin{path: pc.GetString("path"), min: pc.GetFloat64("min")}
+		if p.path == "" {
+			return nil, fmt.Errorf("path of [[package]] is required in %s", file)
+		}
+		cfg.packages = append(cfg.packages, p)
+	}
+	return
+}
+
+// packageMin returns the minimum coverage of the package, the last matched one in the config is used.
+func (s *gateConfig) packageMin(pkg string) (min float64, ok bool) {
+	for _, p := range s.packages {
+		if p.match(pkg) {
+			min, ok = p.min, true
+		}
+	}
+	return
+}
+
+type gateFailure struct {
+	name     string
+	coverage float64
+	min      float64
+}
+
+// check returns the packages and total whose coverage is below the minimum.
+func (s *gateConfig) check(profile *coverProfile) (failures []*gateFailure) {
+	stats := profile.packageStats()
+	var pkgs []string
+	for k := range stats {
+		pkgs = append(pkgs, k)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		if min, ok := s.packageMin(pkg); ok && stats[pkg].Percent() < min {
+			failures = append(failures, &gateFailure{name: pkg, coverage: stats[pkg].Percent(), min: min})
+		}
+	}
+	if total := profile.total().Percent(); s.min > 0 && total < s.min {
+		failures = append(failures, &gateFailure{name: "total", coverage: total, min: s.min})
+	}
+	return
+}
+
+func printGateFailures(failures []*gateFailure) {
+	fmt.Println("\ncoverage below the minimum:")
+	fmt.Printf("%-60s %10s %10s\n", "PACKAGE", "COVERAGE", "MIN")
+	for _, f := range failures {
+		fmt.Printf("%-60s %10s %10s\n", f.name, formatPercent(f.coverage), formatPercent(f.min))
+	}
+}
@@ -0,0 +1,255 @@
+package cover
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snail007/gmc"
+	gcore "github.com/snail007/gmc/core"
+	gtemplate "github.com/snail007/gmc/http/template"
+	gview "github.com/snail007/gmc/http/view"
+	gctx "github.com/snail007/gmc/module/ctx"
+	glog "github.com/snail007/gmc/module/log"
+	gcond "github.com/snail007/gmc/util/cond"
+	gnet "github.com/snail007/gmc/util/net"
+	ghook "github.com/snail007/gmc/util/process/hook"
+	"github.com/snail007/gmct/module/cover/template"
+	"github.com/snail007/gmct/module/go/static"
+	"github.com/snail007/gmct/util/embedfs"
+	"github.com/spf13/cobra"
+)
+
+const defaultHistoryFile = ".gmct/cover-history"
+
+// historyRecord is a line of the history file, the coverage percent of each package and total in a run.
+type historyRecord struct {
+	Time     time.Time          `json:"time"`
+	Commit   string             `json:"commit"`
+	Total    float64            `json:"total"`
+	Packages map[string]float64 `json:"packages"`
+}
+
+func (s *historyRecord) shortCommit() string {
+	if len(s.Commit) > 8 {
+		return s.Commit[:8]
+	}
+	return gcond.Cond(s.Commit == "", "-", s.Commit).String()
+}
+
+// appendHistory appends the coverage of profile to the history file, the commit is empty if not in a git repository.
+func appendHistory(file string, profile *coverProfile) (err error) {
+	commit, _ := gitOutput("rev-parse", "HEAD")
+	r := &historyRecord{
+		Time:     time.Now(),
+		Commit:   commit,
+		Total:    profile.total().Percent(),
+		Packages: map[string]float64{},
+	}
+	for pkg, stat := range profile.packageStats() {
+		r.Packages[pkg] = stat.Percent()
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return
+}
+
+func loadHistory(file string) (records []*historyRecord, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return readHistory(f)
+}
+
+func readHistory(r io.Reader) (records []*historyRecord, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rec := &historyRecord{}
+		if err = json.Unmarshal([]byte(line), rec); err != nil {
+			return nil, fmt.Errorf("invalid history record at line %d, error: %s", n, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+const (
+	chartWidth  = 600
+	chartHeight = 150
+)
+
+type chartPoint struct {
+	X, Y  int
+	Title string
+}
+
+// trendChart is the coverage trend of a package, Points is the polyline points of the svg chart.
+type trendChart struct {
+	Name   string
+	Latest string
+	Change string
+	Points string
+	Dots   []chartPoint
+	Width  int
+	Height int
+}
+
+// newTrendChart returns the chart of the package, the runs the package not in are skipped.
+func newTrendChart(name string, records []*historyRecord, value func(r *historyRecord) (float64, bool)) *trendChart {
+	c := &trendChart{Name: name, Width: chartWidth, Height: chartHeight}
+	var points []string
+	var first, last float64
+	step := float64(chartWidth)
+	if len(records) > 1 {
+		step = float64(chartWidth) / float64(len(records)-1)
+	}
+	for i, r := range records {
+		v, ok := value(r)
+		if !ok {
+			continue
+		}
+		if len(c.Dots) == 0 {
+			first = v
+		}
+		last = v
+		p := chartPoint{
+			X:     int(float64(i) * step),
+			Y:     chartHeight - int(v*chartHeight/100),
+			Title: fmt.Sprintf("%s %s %s", r.Time.Format("2006-01-02 15:04:05"), r.shortCommit(), formatPercent(v)),
+		}
+		c.Dots = append(c.Dots, p)
+		points = append(points, fmt.Sprintf("%d,%d", p.X, p.Y))
+	}
+	c.Points = strings.Join(points, " ")
+	c.Latest = formatPercent(last)
+	c.Change = fmt.Sprintf("%+.1f%%", last-first)
+	return c
+}
+
+// historyCharts returns the chart of total and the charts of packages sorted by name.
+func historyCharts(records []*historyRecord) (total *trendChart, packages []*trendChart) {
+	total = newTrendChart("total", records, func(r *historyRecord) (float64, bool) {
+		return r.Total, true
+	})
+	names := map[string]bool{}
+	for _, r := range records {
+		for pkg := range r.Packages {
+			names[pkg] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		pkg := name
+		packages = append(packages, newTrendChart(pkg, records, func(r *historyRecord) (float64, bool) {
+			v, ok := r.Packages[pkg]
+			return v, ok
+		}))
+	}
+	return
+}
+
+type historyServer struct {
+	server gcore.APIServer
+	tpl    *gtemplate.Template
+	file   string
+}
+
+func newHistoryServer(addr, file string) (s *historyServer, err error) {
+	server, err := gmc.New.APIServer(gctx.NewCtx(), addr)
+	if err != nil {
+		return
+	}
+	s = &historyServer{server: server, file: file}
+	s.tpl, err = gtemplate.NewTemplate(gctx.NewCtx(), "")
+	if err != nil {
+		return
+	}
+	s.tpl.DisableLoadDefaultBinData()
+	embedfs.ParseTemplate(s.tpl, template.Files, "tpl/")
+	s.tpl.DdisableLogging()
+	if err = s.tpl.Parse(); err != nil {
+		return
+	}
+	embedfs.Serve(s.server.Router(), static.Files, "/static/")
+	s.server.Router().HandlerFuncAny("/", func(w http.ResponseWriter, r *http.Request) {
+		// the history file is loaded every request, so the new records are shown after refreshing.
+		records, err := loadHistory(s.file)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		total, packages := historyCharts(records)
+		var runs []*historyRecord
+		for i := len(records) - 1; i >= 0; i-- {
+			runs = append(runs, records[i])
+		}
+		gview.New(w, s.tpl).Layout("layout/page").
+			Set("title", "coverage history").
+			Set("total", total).
+			Set("packages", packages).
+			Set("runs", runs).
+			Render("history/index")
+	})
+	s.server.Logger().SetOutput(glog.NewLoggerWriter(io.Discard))
+	return
+}
+
+func historyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "show the coverage trend recorded by gmct cover --record in browser",
+		RunE: func(c *cobra.Command, a []string) (err error) {
+			file, _ := c.Flags().GetString("file")
+			addr, _ := c.Flags().GetString("addr")
+			if _, err = loadHistory(file); err != nil {
+				return
+			}
+			c.SilenceUsage = true
+			host, port, _ := net.SplitHostPort(gcond.Cond(strings.Contains(addr, ":"), addr, ":"+addr).String())
+			s, err := newHistoryServer(net.JoinHostPort(host, port), file)
+			if err != nil {
+				return
+			}
+			if err = s.server.Run(); err != nil {
+				return
+			}
+			glog.Infof("coverage history http server on http://127.0.0.1:%s", gnet.NewTCPAddr(s.server.Address()).Port())
+			ghook.WaitShutdown()
+			return
+		},
+	}
+	cmd.Flags().String("file", defaultHistoryFile, "the coverage history file")
+	cmd.Flags().String("addr", "127.0.0.1:0", "ip:port http server listen on")
+	return cmd
+}
@@ -0,0 +1,126 @@
+package cover
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr,omitempty"`
+	Contents string `xml:",chardata"`
+}
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// newJunitTestSuite converts the package result to a test suite, a package which fails without failed tests,
+// such as build failed, is reported as an error.
+func newJunitTestSuite(r *packageResult, timestamp time.Time) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      r.importPath,
+		Time:      junitTime(r.elapsed),
+		Timestamp: timestamp.Format("2006-01-02T15:04:05"),
+	}
+	for _, t := range r.tests {
+		c := junitTestCase{Name: t.name, Classname: r.importPath, Time: junitTime(t.elapsed)}
+		switch t.status {
+		case statusFail:
+			c.Failure = &junitMessage{Message: "Failed", Type: "failure", Contents: strings.Join(t.output, "\n")}
+			suite.Failures++
+		case statusSkip:
+			c.Skipped = &junitMessage{Message: strings.TrimSpace(strings.Join(t.output, "\n"))}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if r.failed() && suite.Failures == 0 {
+		output := append([]string{}, r.output...)
+		for _, line := range strings.Split(strings.TrimSpace(r.stderr), "\n") {
+			if line != "" && !strings.Contains(line, "warning: no packages being tested depend on matches for pattern") {
+				output = append(output, line)
+			}
+		}
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "Failure",
+			Classname: r.importPath,
+			Time:      junitTime(r.elapsed),
+			Error:     &junitMessage{Message: "Failed", Type: "error", Contents: strings.Join(output, "\n")},
+		})
+		suite.Errors++
+	}
+	suite.Tests = len(suite.Cases)
+	if len(r.output) > 0 {
+		suite.SystemOut = strings.Join(r.output, "\n")
+	}
+	return suite
+}
+
+// writeJunit writes the results of all packages to file in JUnit XML format, the packages are the test suites.
+func writeJunit(file string, results []*packageResult, timestamp time.Time) (err error) {
+	suites := &junitTestSuites{}
+	var elapsed float64
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		suite := newJunitTestSuite(r, timestamp)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		elapsed += r.elapsed
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitTime(elapsed)
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err = f.WriteString(xml.Header); err != nil {
+		return
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err = enc.Encode(suites); err != nil {
+		return
+	}
+	_, err = f.WriteString("\n")
+	return
+}
@@ -0,0 +1,50 @@
+package cover
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJunitTestSuite(t *testing.T) {
+	assert := assert.New(t)
+	events := `{"Action":"run","Package":"example.com/a","Test":"TestA"}
+{"Action":"output","Package":"example.com/a","Test":"TestA","Output":"    a_test.go:10: want 1\n"}
+{"Action":"fail","Package":"example.com/a","Test":"TestA","Elapsed":0.01}
+{"Action":"run","Package":"example.com/a","Test":"TestB"}
+{"Action":"output","Package":"example.com/a","Test":"TestB","Output":"    a_test.go:20: no network\n"}
+{"Action":"skip","Package":"example.com/a","Test":"TestB","Elapsed":0}
+{"Action":"pass","Package":"example.com/a","Test":"TestC","Elapsed":0.5}
+{"Action":"output","Package":"example.com/a","Output":"FAIL\texample.com/a\t0.600s\n"}
+{"Action":"fail","Package":"example.com/a","Elapsed":0.6}
+`
+	r := newPackageResult("example.com/a")
+	assert.NoError(r.parse(strings.NewReader(events)))
+	assert.True(r.failed())
+	assert.Equal("FAIL\texample.com/a\t0.600s", r.summary())
+	suite := newJunitTestSuite(r, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	assert.Equal("example.com/a", suite.Name)
+	assert.Equal("0.600", suite.Time)
+	assert.Equal("2024-01-02T03:04:05", suite.Timestamp)
+	assert.Equal(3, suite.Tests)
+	assert.Equal(1, suite.Failures)
+	assert.Equal(1, suite.Skipped)
+	assert.Equal(0, suite.Errors)
+	assert.Equal("    a_test.go:10: want 1", suite.Cases[0].Failure.Contents)
+	assert.Equal("a_test.go:20: no network", suite.Cases[1].Skipped.Message)
+	assert.Nil(suite.Cases[2].Failure)
+	assert.Equal("0.500", suite.Cases[2].Time)
+
+	// build failed, there is no test events, and the package is reported as an error.
+	r = newPackageResult("example.com/b")
+	assert.NoError(r.parse(strings.NewReader("# example.com/b\nb.go:3:1: syntax error\n")))
+	r.err = errors.New("exit status 1")
+	r.stderr = "warning: no packages being tested depend on matches for pattern example.com/c\n"
+	suite = newJunitTestSuite(r, time.Now())
+	assert.Equal(1, suite.Tests)
+	assert.Equal(1, suite.Errors)
+	assert.Equal("# example.com/b\nb.go:3:1: syntax error", suite.Cases[0].Error.Contents)
+}
@@ -0,0 +1,114 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goPackage is the package information output by go list -json.
+type goPackage struct {
+	ImportPath   string
+	Dir          string
+	Name         string
+	GoFiles      []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Deps         []string
+	TestImports  []string
+	XTestImports []string
+	Module       *goModule
+}
+
+type goModule struct {
+	Path string
+	Dir  string
+	Main bool
+}
+
+func (s *goPackage) hasTestFile() bool {
+	return len(s.TestGoFiles) > 0 || len(s.XTestGoFiles) > 0
+}
+
+// goList runs go list -json in the dir, f is called to decode each json object in the output until io.EOF.
+func goList(dir string, args []string, f func(d *json.Decoder) error) (err error) {
+	cmd := exec.Command("go", append([]string{"list", "-e", "-json"}, args...)...)
+	cmd.Dir = dir
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("go list %s fail, error: %s, output: %s", strings.Join(args, " "), err, stderr.String())
+	}
+	d := json.NewDecoder(bytes.NewReader(out))
+	for {
+		err = f(d)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// listPatterns returns the package patterns to test. In workspace mode, the pattern ./... not works
+// if current directory is not in a module, so the workspace modules in current directory are used.
+func listPatterns(only bool) (patterns []string, err error) {
+	if only {
+		return []string{"./"}, nil
+	}
+	out, err := exec.Command("go", "env", "GOWORK").Output()
+	if err != nil {
+		return
+	}
+	gowork := strings.TrimSpace(string(out))
+	if gowork == "" || gowork == "off" {
+		return []string{"./..."}, nil
+	}
+	pwd, _ := os.Getwd()
+	err = goList(".", []string{"-m"}, func(d *json.Decoder) error {
+		m := &goModule{}
+		if e := d.Decode(m); e != nil {
+			return e
+		}
+		if rel, e := filepath.Rel(pwd, m.Dir); e == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			patterns = append(patterns, m.Path+"/...")
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if len(patterns) == 0 {
+		return []string{"./..."}, nil
+	}
+	return
+}
+
+// listPackages lists the packages to test, main, vendor and examples packages are ignored.
+func listPackages(only bool) (packages []*goPackage, err error) {
+	patterns, err := listPatterns(only)
+	if err != nil {
+		return
+	}
+	err = goList(".", patterns, func(d *json.Decoder) error {
+		p := &goPackage{}
+		if e := d.Decode(p); e != nil {
+			return e
+		}
+		for _, v := range []string{"/main", "/vendor", "/examples"} {
+			if strings.Contains(p.ImportPath, v) {
+				return nil
+			}
+		}
+		packages = append(packages, p)
+		return nil
+	})
+	return
+}
@@ -0,0 +1,188 @@
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// profileBlock is a code block in the coverage profile, such as: a.go:4.2,5.1 1 0
+type profileBlock struct {
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+	Count     int
+}
+
+type blockKey struct {
+	startLine, startCol, endLine, endCol int
+}
+
+// coverProfile is the merged coverage profile of all packages, the same block in different profiles
+// is merged into one, its count is the sum of all.
+type coverProfile struct {
+	mode   string
+	files  map[string][]*profileBlock
+	blocks map[string]map[blockKey]*profileBlock
+}
+
+// coverStat is the statements count of a package or file.
+type coverStat struct {
+	Statements int
+	Covered    int
+}
+
+func (s coverStat) Percent() float64 {
+	if s.Statements == 0 {
+		return 0
+	}
+	return float64(s.Covered) * 100 / float64(s.Statements)
+}
+
+func newCoverProfile(mode string) *coverProfile {
+	return &coverProfile{
+		mode:   mode,
+		files:  map[string][]*profileBlock{},
+		blocks: map[string]map[blockKey]*profileBlock{},
+	}
+}
+
+// addFile merges the profile file, the first line of the file is mode: xxx.
+func (s *coverProfile) addFile(file string) (err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return s.addReader(f)
+}
+
+func (s *coverProfile) addReader(r io.Reader) (err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		if err = s.addLine(line); err != nil {
+			return
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *coverProfile) addLine(line string) (err error) {
+	// line format: name.go:line.column,line.column numberOfStatements count
+	i := strings.LastIndex(line, ":")
+	if i < 0 {
+		return fmt.Errorf("invalid coverage profile line: %s", line)
+	}
+	file := line[:i]
+	b := &profileBlock{}
+	_, err = fmt.Sscanf(line[i+1:], "%d.%d,%d.%d %d %d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol, &b.NumStmt, &b.Count)
+	if err != nil {
+		return fmt.Errorf("invalid coverage profile line: %s", line)
+	}
+	key := blockKey{b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+	if s.blocks[file] == nil {
+		s.blocks[file] = map[blockKey]*profileBlock{}
+	}
+	if exists, ok := s.blocks[file][key]; ok {
+		if s.mode == "set" {
+			if b.Count > 0 {
+				exists.Count = 1
+			}
+		} else {
+			exists.Count += b.Count
+		}
+		return
+	}
+	s.blocks[file][key] = b
+	s.files[file] = append(s.files[file], b)
+	return
+}
+
+// fileNames returns the sorted file names in the profile.
+func (s *coverProfile) fileNames() (names []string) {
+	for k := range s.files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return
+}
+
+// fileBlocks returns the blocks of file sorted by position.
+func (s *coverProfile) fileBlocks(file string) []*profileBlock {
+	blocks := s.files[file]
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].StartLine != blocks[j].StartLine {
+			return blocks[i].StartLine < blocks[j].StartLine
+		}
+		return blocks[i].StartCol < blocks[j].StartCol
+	})
+	return blocks
+}
+
+func (s *coverProfile) write(w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", s.mode)
+	for _, file := range s.fileNames() {
+		for _, b := range s.fileBlocks(file) {
+			fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", file, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+		}
+	}
+	return bw.Flush()
+}
+
+func (s *coverProfile) writeFile(file string) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return s.write(f)
+}
+
+func (s *coverProfile) fileStat(file string) (stat coverStat) {
+	for _, b := range s.files[file] {
+		stat.Statements += b.NumStmt
+		if b.Count > 0 {
+			stat.Covered += b.NumStmt
+		}
+	}
+	return
+}
+
+// packageStats returns the statements count of each package, the key is the import path.
+func (s *coverProfile) packageStats() map[string]coverStat {
+	stats := map[string]coverStat{}
+	for file := range s.files {
+		pkg := path.Dir(file)
+		st := s.fileStat(file)
+		v := stats[pkg]
+		v.Statements += st.Statements
+		v.Covered += st.Covered
+		stats[pkg] = v
+	}
+	return stats
+}
+
+func (s *coverProfile) total() (stat coverStat) {
+	for _, v := range s.packageStats() {
+		stat.Statements += v.Statements
+		stat.Covered += v.Covered
+	}
+	return
+}
+
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64) + "%"
+}
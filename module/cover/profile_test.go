@@ -0,0 +1,46 @@
+package cover
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverProfileMerge(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		mode  string
+		count string
+	}{
+		{"set", "1"},
+		{"count", "5"},
+		{"atomic", "5"},
+	} {
+		p := newCoverProfile(c.mode)
+		assert.NoError(p.addReader(strings.NewReader("mode: " + c.mode + "\nexample.com/a/a.go:3.10,5.2 2 2\nexample.com/a/a.go:7.10,9.2 1 0\n")))
+		assert.NoError(p.addReader(strings.NewReader("mode: " + c.mode + "\nexample.com/a/a.go:3.10,5.2 2 3\nexample.com/b/b.go:1.1,2.2 1 0\n")))
+		buf := &bytes.Buffer{}
+		assert.NoError(p.write(buf))
+		assert.Equal("mode: "+c.mode+"\nexample.com/a/a.go:3.10,5.2 2 "+c.count+"\nexample.com/a/a.go:7.10,9.2 1 0\nexample.com/b/b.go:1.1,2.2 1 0\n",
+			buf.String(), c.mode)
+		assert.Equal(coverStat{Statements: 4, Covered: 2}, p.total(), c.mode)
+		assert.Equal(coverStat{Statements: 3, Covered: 2}, p.packageStats()["example.com/a"], c.mode)
+	}
+	p := newCoverProfile("set")
+	assert.Error(p.addLine("example.com/a/a.go:3.10,5.2 2"))
+	assert.Error(p.addLine("a.go 3.10,5.2 2 1"))
+}
+
+func TestLineHits(t *testing.T) {
+	assert := assert.New(t)
+	p := newCoverProfile("count")
+	assert.NoError(p.addLine("example.com/a/a.go:5.2,7.3 1 0"))
+	assert.NoError(p.addLine("example.com/a/a.go:3.10,5.2 2 4"))
+	assert.NoError(p.addLine("example.com/a/a.go:10.1,10.20 1 0"))
+	lines, hits := (&exporter{profile: p}).lineHits("example.com/a/a.go")
+	assert.Equal([]int{3, 4, 5, 6, 7, 10}, lines)
+	// the line shared by two blocks uses the maximum count.
+	assert.Equal(map[int]int{3: 4, 4: 4, 5: 4, 6: 0, 7: 0, 10: 0}, hits)
+}
@@ -0,0 +1,6 @@
+package template
+
+import "embed"
+
+//go:embed tpl
+var Files embed.FS
@@ -0,0 +1,181 @@
+package cover
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+	statusSkip = "skip"
+)
+
+// testEvent is the event of go test -json, see: go doc test2json
+type testEvent struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// testCase is the result of a test function, output is the output lines of the test.
+type testCase struct {
+	name    string
+	status  string
+	elapsed float64
+	output  []string
+}
+
+// packageResult is the result of testing a package, output is the package level output lines,
+// lines is all the output lines in order, stderr is the output of go test which is not in json, such as build errors.
+type packageResult struct {
+	importPath string
+	status     string
+	elapsed    float64
+	tests      []*testCase
+	output     []string
+	lines      []string
+	stderr     string
+	err        error
+}
+
+func newPackageResult(importPath string) *packageResult {
+	return &packageResult{importPath: importPath}
+}
+
+// parse reads the events of go test -json, the lines not in json are kept as package level output.
+func (s *packageResult) parse(r io.Reader) (err error) {
+	tests := map[string]*testCase{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		e := &testEvent{}
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), e) != nil {
+			s.output = append(s.output, line)
+			s.lines = append(s.lines, line)
+			continue
+		}
+		if e.Action == "build-output" {
+			// build errors are reported in json since go1.24, and in stderr before.
+			s.output = append(s.output, strings.TrimSuffix(e.Output, "\n"))
+			s.lines = append(s.lines, strings.TrimSuffix(e.Output, "\n"))
+			continue
+		}
+		if e.Package != "" && e.Package != s.importPath {
+			continue
+		}
+		if e.Action == "output" {
+			s.lines = append(s.lines, strings.TrimSuffix(e.Output, "\n"))
+		}
+		if e.Test == "" {
+			switch e.Action {
+			case "output":
+				s.output = append(s.output, strings.TrimSuffix(e.Output, "\n"))
+			case statusPass, statusFail, statusSkip:
+				s.status, s.elapsed = e.Action, e.Elapsed
+			}
+			continue
+		}
+		t, ok := tests[e.Test]
+		if !ok {
+			t = &testCase{name: e.Test}
+			tests[e.Test] = t
+			s.tests = append(s.tests, t)
+		}
+		switch e.Action {
+		case "output":
+			t.output = append(t.output, strings.TrimSuffix(e.Output, "\n"))
+		case statusPass, statusFail, statusSkip:
+			t.status, t.elapsed = e.Action, e.Elapsed
+		}
+	}
+	return scanner.Err()
+}
+
+// failed returns true if the package fails, go test fails without a fail event when the package can not be built.
+func (s *packageResult) failed() bool {
+	return s.status == statusFail || (s.status == "" && s.err != nil)
+}
+
+// failedTests returns the failed tests, the parent test fails if any of its sub tests fails,
+// so only the deepest failed tests are returned.
+func (s *packageResult) failedTests() (tests []*testCase) {
+	for _, t := range s.tests {
+		if t.status != statusFail {
+			continue
+		}
+		hasFailedSub := false
+		for _, sub := range s.tests {
+			if sub.status == statusFail && strings.HasPrefix(sub.name, t.name+"/") {
+				hasFailedSub = true
+				break
+			}
+		}
+		if !hasFailedSub {
+			tests = append(tests, t)
+		}
+	}
+	return
+}
+
+// summary returns the result line of the package, such as: ok  	foo/bar	0.01s	coverage: 50.0%
+func (s *packageResult) summary() string {
+	for i := len(s.output) - 1; i >= 0; i-- {
+		line := s.output[i]
+		if strings.HasPrefix(line, "ok ") || strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "?") {
+			return strings.Split(line, " of statements in")[0]
+		}
+	}
+	switch {
+	case s.failed():
+		return fmt.Sprintf("FAIL\t%s\t%.3fs", s.importPath, s.elapsed)
+	case s.status == statusSkip:
+		return fmt.Sprintf("?   \t%s\t[no test files]", s.importPath)
+	}
+	return fmt.Sprintf("ok  \t%s\t%.3fs", s.importPath, s.elapsed)
+}
+
+func printFailureSummary(results []*packageResult) (failed int) {
+	for _, r := range results {
+		if r == nil || !r.failed() {
+			continue
+		}
+		if failed == 0 {
+			fmt.Println("\nfailed packages:")
+		}
+		failed++
+		fmt.Printf("--- FAIL: %s (%.3fs)\n", r.importPath, r.elapsed)
+		tests := r.failedTests()
+		for _, t := range tests {
+			fmt.Printf("    --- FAIL: %s (%.2fs)\n", t.name, t.elapsed)
+			for _, line := range t.output {
+				if strings.HasPrefix(strings.TrimSpace(line), "--- FAIL:") || strings.HasPrefix(line, "=== ") {
+					continue
+				}
+				fmt.Println("        " + strings.TrimSpace(line))
+			}
+		}
+		if len(tests) == 0 {
+			// build failed or panic outside tests, print the output of package.
+			for _, line := range r.output {
+				if line != "" && line != "FAIL" && !strings.HasPrefix(line, "FAIL\t") && !strings.HasPrefix(line, "FAIL ") {
+					fmt.Println("        " + line)
+				}
+			}
+			for _, line := range strings.Split(strings.TrimSpace(r.stderr), "\n") {
+				if line != "" && !strings.Contains(line, "warning: no packages being tested depend on matches for pattern") {
+					fmt.Println("        " + line)
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,182 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	glog "github.com/snail007/gmc/module/log"
+	gexec "github.com/snail007/gmc/util/exec"
+	"github.com/spf13/cobra"
+)
+
+const (
+	overlapSkip  = "skip"
+	overlapQueue = "queue"
+	overlapKill  = "kill"
+)
+
+// cronJob runs the command on each schedule, overlap decides what to do if the previous run is still running.
+type cronJob struct {
+	cmdStr  string
+	timeout time.Duration
+	overlap string
+	w, wr   io.Writer
+	runID   int64
+	queue   sync.Mutex
+	lock    sync.Mutex
+	cmd     *gexec.Command
+	done    chan struct{}
+	stopped bool
+	// killPending is set if the kill is requested before the command started.
+	killPending bool
+}
+
+func (s *cronJob) Run() {
+	if s.overlap == overlapQueue {
+		s.queue.Lock()
+		defer s.queue.Unlock()
+	}
+	id := atomic.AddInt64(&s.runID, 1)
+	s.lock.Lock()
+	// check again after the previous run done, another run may be started by the other waiting tick.
+	for s.done != nil {
+		prev, done := s.cmd, s.done
+		if s.overlap == overlapSkip {
+			s.lock.Unlock()
+			glog.Infof("run #%d skipped, previous run is still running", id)
+			return
+		}
+		// the previous command may be not started yet, then it is killed once started.
+		s.killPending = prev == nil
+		s.lock.Unlock()
+		glog.Infof("run #%d killing previous run", id)
+		if prev != nil {
+			killCmd(prev.Cmd())
+		}
+		<-done
+		s.lock.Lock()
+	}
+	if s.stopped {
+		s.lock.Unlock()
+		return
+	}
+	done := make(chan struct{})
+	s.done = done
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		s.cmd = nil
+		s.done = nil
+		s.killPending = false
+		s.lock.Unlock()
+		close(done)
+	}()
+	start := time.Now()
+	cmd := gexec.NewCommand(s.cmdStr).
+		Timeout(s.timeout).
+		BeforeExec(func(command *gexec.Command, c *exec.Cmd) {
+			c.Stdout = s.w
+			c.Stderr = s.wr
+		}).
+		AfterExec(func(command *gexec.Command, c *exec.Cmd, err error) {
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.cmd = command
+			kill := s.killPending || s.stopped
+			s.lock.Unlock()
+			glog.Infof("run #%d start, pid: %d", id, c.Process.Pid)
+			if kill {
+				killCmd(c)
+			}
+		})
+	_, err := cmd.Exec()
+	duration := time.Since(start).Truncate(time.Millisecond)
+	if cmd.Cmd() == nil || cmd.Cmd().ProcessState == nil {
+		glog.Warnf("run #%d fail, duration: %s, error: %s", id, duration, err)
+		return
+	}
+	state := cmd.Cmd().ProcessState
+	if !state.Exited() {
+		// killed by timeout, overlap kill or signal.
+		glog.Infof("run #%d end, exit code: %d (%s), duration: %s", id, state.ExitCode(), state.String(), duration)
+		return
+	}
+	glog.Infof("run #%d end, exit code: %d, duration: %s", id, state.ExitCode(), duration)
+}
+
+// stop kills the running command, and no more runs will be started.
+func (s *cronJob) stop() {
+	s.lock.Lock()
+	s.stopped = true
+	cmd := s.cmd
+	s.lock.Unlock()
+	if cmd != nil && cmd.Cmd() != nil && cmd.Cmd().Process != nil {
+		killCmd(cmd.Cmd())
+	}
+}
+
+func cronCommand() *cobra.Command {
+	cmdCron := &cobra.Command{
+		Use: "cron <spec> <command>",
+		Long: `exec the command on the cron schedule, spec is standard cron expression with an optional seconds field,
+such as: "*/10 * * * *", "0 30 * * * *", or descriptors: @hourly, @daily, @every 1h30m`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(c *cobra.Command, a []string) error {
+			timeout, _ := c.Flags().GetInt("timeout")
+			overlap, _ := c.Flags().GetString("overlap")
+			if overlap != overlapSkip && overlap != overlapQueue && overlap != overlapKill {
+				return fmt.Errorf("unknown overlap: %s, should be one of: %s, %s, %s", overlap, overlapSkip, overlapQueue, overlapKill)
+			}
+			parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+			schedule, err := parser.Parse(a[0])
+			if err != nil {
+				return fmt.Errorf("invalid cron spec: %s, error: %s", a[0], err)
+			}
+			pArgs := newProcessArgs(c)
+			w, wr, err := pArgs.prepare()
+			if err != nil {
+				return err
+			}
+			defer removePidFile(pArgs.pidFile)
+			job := &cronJob{
+				cmdStr:  a[1],
+				timeout: time.Second * time.Duration(timeout),
+				overlap: overlap,
+				w:       w,
+				wr:      wr,
+			}
+			signalChan := make(chan os.Signal, 1)
+			signal.Notify(signalChan,
+				os.Interrupt,
+				syscall.SIGHUP,
+				syscall.SIGINT,
+				syscall.SIGTERM,
+				syscall.SIGQUIT)
+			scheduler := cron.New(cron.WithParser(parser))
+			scheduler.Schedule(schedule, job)
+			scheduler.Start()
+			glog.Infof("cron started, next run at: %s", schedule.Next(time.Now()).Format(time.RFC3339))
+			sig := <-signalChan
+			ctx := scheduler.Stop()
+			job.stop()
+			// wait for the running jobs to exit after the current run is killed.
+			<-ctx.Done()
+			glog.Infof("exited with signal: %v", sig.String())
+			return nil
+		},
+	}
+	cmdCron.Flags().IntP("timeout", "t", 0, "timeout seconds of each run, exceeded will be kill. 0: unlimited")
+	cmdCron.Flags().String("overlap", overlapSkip, "what to do if previous run is still running: skip, queue or kill, kill means kill the previous run")
+	bindProcessFlags(cmdCron.Flags())
+	return cmdCron
+}
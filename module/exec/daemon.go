@@ -0,0 +1,181 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	glog "github.com/snail007/gmc/module/log"
+	gfile "github.com/snail007/gmc/util/file"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// stopGracePeriod is the time to wait for the command exited after SIGTERM sent, then it is killed,
+// it is shorter than the default timeout of gmct exec stop, so the command is not left running
+// after the daemon killed.
+const stopGracePeriod = 5 * time.Second
+
+// processArgs are the options of running in background and logging output, used by exec retry and exec cron.
+type processArgs struct {
+	daemon     bool
+	output     string
+	pidFile    string
+	maxSize    int64
+	maxBackups int
+}
+
+func bindProcessFlags(flags *pflag.FlagSet) {
+	flags.StringP("output", "o", "", "the file logging output to")
+	flags.Int64("max-size", 0, "rotate the output file when its size exceeds the megabytes, 0: no rotation")
+	flags.Int("max-backups", 3, "maximum number of rotated output files to keep")
+	flags.BoolP("daemon", "d", false, "running in background, it is detached from the terminal")
+	flags.String("pid-file", "", "the file to write the pid to, it can be used by: gmct exec stop --pid-file")
+}
+
+func newProcessArgs(c *cobra.Command) *processArgs {
+	s := &processArgs{}
+	s.daemon, _ = c.Flags().GetBool("daemon")
+	s.output, _ = c.Flags().GetString("output")
+	s.pidFile, _ = c.Flags().GetString("pid-file")
+	s.maxSize, _ = c.Flags().GetInt64("max-size")
+	s.maxBackups, _ = c.Flags().GetInt("max-backups")
+	return s
+}
+
+// prepare starts current command in background and exits if daemon is set,
+// otherwise it opens the output file and writes the pid file, w and wr are the stdout and stderr of the command to run.
+func (s *processArgs) prepare() (w, wr io.Writer, err error) {
+	if s.daemon {
+		if err = daemonize(s.pidFile); err != nil {
+			return
+		}
+		os.Exit(0)
+	}
+	w, wr = os.Stdout, os.Stderr
+	if s.output != "" {
+		f, e := newRotateWriter(s.output, s.maxSize*1024*1024, s.maxBackups)
+		if e != nil {
+			return nil, nil, e
+		}
+		w, wr = f, f
+		glog.SetOutput(glog.NewLoggerWriter(f))
+	}
+	if err = checkPidFile(s.pidFile); err != nil {
+		return
+	}
+	if s.pidFile != "" {
+		err = writePidFile(s.pidFile, os.Getpid())
+	}
+	return
+}
+
+// exit removes the pid file and exits with the code.
+func (s *processArgs) exit(code int) {
+	removePidFile(s.pidFile)
+	os.Exit(code)
+}
+
+// daemonize starts current command in background without -d, the child process is running in a new session,
+// and its stdio are redirected to the null device, the output is logged to the file of option --output.
+func daemonize(pidFile string) (err error) {
+	if err = checkPidFile(pidFile); err != nil {
+		return
+	}
+	var args []string
+	for _, v := range os.Args[1:] {
+		if v != "-d" && v != "--daemon" {
+			args = append(args, v)
+		}
+	}
+	dCmd := exec.Command(os.Args[0], args...)
+	setSid(dCmd)
+	if err = dCmd.Start(); err != nil {
+		return fmt.Errorf("fail to running in background, error: %v", err)
+	}
+	if pidFile != "" {
+		// the child writes the pid file too, write it here to make it available once this command returned.
+		if err = writePidFile(pidFile, dCmd.Process.Pid); err != nil {
+			return
+		}
+	}
+	glog.Infof("running in background, pid: %v", dCmd.Process.Pid)
+	return dCmd.Process.Release()
+}
+
+// checkPidFile returns error if the process in the pid file is still running.
+func checkPidFile(pidFile string) error {
+	if pidFile == "" {
+		return nil
+	}
+	pid, err := readPidFile(pidFile)
+	if err != nil {
+		return nil
+	}
+	if pid != os.Getpid() && processExists(pid) {
+		return fmt.Errorf("process %d in pid file %s is still running", pid, pidFile)
+	}
+	return nil
+}
+
+func readPidFile(pidFile string) (int, error) {
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid file: %s", pidFile)
+	}
+	return pid, nil
+}
+
+func writePidFile(pidFile string, pid int) error {
+	return os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644)
+}
+
+// removePidFile removes the pid file only if it belongs to current process.
+func removePidFile(pidFile string) {
+	if pidFile == "" {
+		return
+	}
+	if pid, err := readPidFile(pidFile); err == nil && pid == os.Getpid() {
+		os.Remove(pidFile)
+	}
+}
+
+// stopDaemon sends SIGTERM to the process group in the pid file, and sends SIGKILL if it is not exited after timeout.
+func stopDaemon(pidFile string, timeout time.Duration) (err error) {
+	if !gfile.Exists(pidFile) {
+		return fmt.Errorf("pid file not found: %s", pidFile)
+	}
+	pid, err := readPidFile(pidFile)
+	if err != nil {
+		return
+	}
+	if !processExists(pid) {
+		os.Remove(pidFile)
+		return fmt.Errorf("process %d is not running, stale pid file removed", pid)
+	}
+	if err = signalGroup(pid, syscall.SIGTERM); err != nil {
+		return
+	}
+	glog.Infof("SIGTERM sent to %d, waiting for exited", pid)
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(time.Millisecond * 100) {
+		if !processExists(pid) {
+			os.Remove(pidFile)
+			glog.Infof("process %d stopped", pid)
+			return
+		}
+	}
+	glog.Warnf("process %d not exited after %s, killing", pid, timeout)
+	killChildGroups(pid)
+	signalGroup(pid, syscall.SIGKILL)
+	os.Remove(pidFile)
+	return
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package exec
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setSid makes the command running in a new session, it is detached from the terminal.
+func setSid(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// signalGroup sends the signal to the process group of pid, and to the process only if pid is not a group leader.
+func signalGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return nil
+	}
+	return syscall.Kill(pid, sig)
+}
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// killChildGroups kills the process groups of the children of pid, the commands started by the daemon are
+// running in their own process groups, which are not killed with the group of daemon.
+func killChildGroups(pid int) {
+	out, _ := exec.Command("pgrep", "-P", strconv.Itoa(pid)).Output()
+	for _, v := range strings.Fields(string(out)) {
+		if child, err := strconv.Atoi(v); err == nil {
+			signalGroup(child, syscall.SIGKILL)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setSid(c *exec.Cmd) {}
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+func processExists(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
+
+func killChildGroups(pid int) {}
@@ -27,39 +27,27 @@ func init() {
 				if len(a) == 0 {
 					return fmt.Errorf("command string is required")
 				}
-				daemon, _ := c.Flags().GetBool("daemon")
-				output, _ := c.Flags().GetString("output")
 				maxCount, _ := c.Flags().GetInt("count")
 				seconds, _ := c.Flags().GetInt("sleep")
 				timeout, _ := c.Flags().GetInt("timeout")
+				backoff, _ := c.Flags().GetString("backoff")
+				maxSleep, _ := c.Flags().GetDuration("max-sleep")
+				jitter, _ := c.Flags().GetBool("jitter")
+				retryOn, _ := c.Flags().GetIntSlice("retry-on")
+				noRetryOn, _ := c.Flags().GetIntSlice("no-retry-on")
 				seconds = gcond.Cond(seconds <= 0, 5, seconds).Int()
 				timeout = gcond.Cond(timeout <= 0, 0, timeout).Int()
-				w, wr := os.Stdout, os.Stderr
-				if output != "" {
-					f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0755)
-					if err != nil {
-						return err
-					}
-					w, wr = f, f
-					glog.SetOutput(glog.NewLoggerWriter(f))
+				policy, err := newRetryPolicy(time.Second*time.Duration(seconds), maxSleep, backoff, jitter, retryOn, noRetryOn)
+				if err != nil {
+					return err
 				}
-				if daemon {
-					var args []string
-					for _, v := range os.Args[1:] {
-						if v != "-d" && v != "--daemon" {
-							args = append(args, v)
-						}
-					}
-					dCmd := exec.Command(os.Args[0], args...)
-					err := dCmd.Start()
-					if err != nil {
-						glog.Errorf("fail to running in background, error: %v", err.Error())
-					} else {
-						glog.Infof("running in background, pid: %v", dCmd.Process.Pid)
-					}
-					os.Exit(0)
-					return nil
+				pArgs := newProcessArgs(c)
+				w, wr, err := pArgs.prepare()
+				if err != nil {
+					return err
 				}
+				exit := pArgs.exit
+				defer removePidFile(pArgs.pidFile)
 				tryCount := 0
 				cmdStr := a[0]
 				signalChan := make(chan os.Signal, 1)
@@ -77,8 +65,10 @@ func init() {
 					}
 				}()
 				var cmd *gexec.Command
+				exited := make(chan struct{})
 				go func() {
 					defer func() {
+						close(exited)
 						select {
 						case signalChan <- syscall.SIGQUIT:
 							break
@@ -88,7 +78,7 @@ func init() {
 					for {
 						if maxCount > 0 && tryCount >= maxCount {
 							glog.Infof("max try count %v reached", maxCount)
-							os.Exit(0)
+							exit(0)
 						}
 						if kill {
 							return
@@ -107,30 +97,55 @@ func init() {
 						if kill {
 							return
 						}
-						if err != nil {
-							tryCount++
-							glog.Infof("process exited with %d, restarting...", cmd.Cmd().ProcessState.ExitCode())
-							time.Sleep(time.Second * time.Duration(seconds))
-						} else {
-							glog.Infof("process exited with %d", cmd.Cmd().ProcessState.ExitCode())
+						exitCode := -1
+						if cmd.Cmd().ProcessState != nil {
+							exitCode = exitCodeOf(cmd.Cmd().ProcessState)
+						}
+						if err == nil {
+							glog.Infof("process exited with %d", exitCode)
 							return
 						}
+						if !policy.shouldRetry(exitCode) {
+							glog.Infof("process exited with %d, not retry on this exit code", exitCode)
+							exit(gcond.Cond(exitCode > 0, exitCode, 1).Int())
+						}
+						tryCount++
+						d := policy.delay(tryCount)
+						glog.Infof("process exited with %d, restarting after %s...", exitCode, d)
+						time.Sleep(d)
 					}
 				}()
 				sig = <-signalChan
 				kill = true
 				if cmd != nil && cmd.Cmd() != nil && cmd.Cmd().Process != nil {
-					killCmd(cmd.Cmd())
+					// the command is running in its own process group, so the SIGTERM sent to this process
+					// is not received by it, stop it gracefully here.
+					terminateCmd(cmd.Cmd())
+					select {
+					case <-exited:
+					case <-time.After(stopGracePeriod):
+						glog.Warnf("process not exited after %s, killing", stopGracePeriod)
+						killCmd(cmd.Cmd())
+						<-exited
+					}
 				}
 				return nil
 			},
 		}
 		cmdRetry.Flags().IntP("timeout", "t", 0, "command timeout seconds, exceeded will be kill. 0: unlimited")
 		cmdRetry.Flags().IntP("sleep", "s", 5, "sleep seconds before restarting")
-		cmdRetry.Flags().StringP("output", "o", "", "the file logging output to")
-		cmdRetry.Flags().BoolP("daemon", "d", false, "running in background")
+		cmdRetry.Flags().String("backoff", backoffFixed, "backoff strategy of sleep between attempts, fixed or exponential, exponential doubles the sleep on each attempt")
+		cmdRetry.Flags().Duration("max-sleep", 5*time.Minute, "maximum sleep between attempts, such as 30s, 5m")
+		cmdRetry.Flags().Bool("jitter", false, "randomize the sleep between half and full of it")
+		cmdRetry.Flags().IntSlice("retry-on", nil, "only retry when the command exited with these codes, such as 1,2,137. default: any non-zero code")
+		cmdRetry.Flags().IntSlice("no-retry-on", nil, "never retry when the command exited with these codes, such as 2")
+		bindProcessFlags(cmdRetry.Flags())
 		cmdRetry.Flags().IntP("count", "c", 0, "maximum try count, 0 means no limit")
 		execCMD.AddCommand(cmdRetry)
+		execCMD.AddCommand(cronCommand())
+		execCMD.AddCommand(parallelCommand())
+		execCMD.AddCommand(watchCommand())
+		execCMD.AddCommand(superviseCommands()...)
 		root.AddCommand(execCMD)
 	})
 }
@@ -11,3 +11,8 @@ import (
 func killCmd(c *exec.Cmd) {
 	syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
 }
+
+// terminateCmd sends SIGTERM to the process group of the command, let the processes exit gracefully.
+func terminateCmd(c *exec.Cmd) {
+	syscall.Kill(-c.Process.Pid, syscall.SIGTERM)
+}
@@ -5,3 +5,7 @@ import "os/exec"
 func killCmd(c *exec.Cmd) {
 	c.Process.Kill()
 }
+
+func terminateCmd(c *exec.Cmd) {
+	c.Process.Kill()
+}
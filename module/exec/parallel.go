@@ -0,0 +1,269 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	glog "github.com/snail007/gmc/module/log"
+	gcond "github.com/snail007/gmc/util/cond"
+	gexec "github.com/snail007/gmc/util/exec"
+	"github.com/snail007/gmc/util/gpool"
+	"github.com/spf13/cobra"
+)
+
+type parallelArgs struct {
+	template  string
+	inputs    []string
+	jobs      int
+	timeout   time.Duration
+	retry     int
+	keepOrder bool
+	tag       bool
+}
+
+type parallelJob struct {
+	seq      int
+	input    string
+	cmd      string
+	output   []byte
+	exitCode int
+	attempts int
+	err      error
+}
+
+type parallel struct {
+	args    parallelArgs
+	lock    sync.Mutex
+	running map[int]*gexec.Command
+	done    map[int]*parallelJob
+	next    int
+	killed  bool
+}
+
+func newParallel(args parallelArgs) *parallel {
+	return &parallel{
+		args:    args,
+		running: map[int]*gexec.Command{},
+		done:    map[int]*parallelJob{},
+	}
+}
+
+// Start runs all jobs and returns the failed jobs.
+func (s *parallel) Start() (failed []*parallelJob) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		sig := <-signalChan
+		s.lock.Lock()
+		s.killed = true
+		for _, cmd := range s.running {
+			if cmd.Cmd() != nil && cmd.Cmd().Process != nil {
+				killCmd(cmd.Cmd())
+			}
+		}
+		s.lock.Unlock()
+		glog.Infof("exited with signal: %v", sig.String())
+		os.Exit(130)
+	}()
+	p := gpool.New(s.args.jobs)
+	jobs := make([]*parallelJob, len(s.args.inputs))
+	for i, input := range s.args.inputs {
+		job := &parallelJob{seq: i + 1, input: input, cmd: expandTemplate(s.args.template, input, i+1)}
+		jobs[i] = job
+		p.Submit(func() {
+			s.run(job)
+			s.finish(job)
+		})
+	}
+	p.WaitDone()
+	p.Stop()
+	for _, job := range jobs {
+		if job.exitCode != 0 {
+			failed = append(failed, job)
+		}
+	}
+	return
+}
+
+func (s *parallel) run(job *parallelJob) {
+	for job.attempts <= s.args.retry {
+		job.attempts++
+		buf := &bytes.Buffer{}
+		cmd := gexec.NewCommand(job.cmd).
+			Timeout(s.args.timeout).
+			Output(buf).
+			AfterExec(func(command *gexec.Command, c *exec.Cmd, err error) {
+				if err != nil {
+					return
+				}
+				s.lock.Lock()
+				s.running[job.seq] = command
+				s.lock.Unlock()
+			})
+		_, job.err = cmd.Exec()
+		s.lock.Lock()
+		delete(s.running, job.seq)
+		killed := s.killed
+		s.lock.Unlock()
+		job.output = buf.Bytes()
+		job.exitCode = 0
+		if job.err != nil {
+			job.exitCode = -1
+			if cmd.Cmd() != nil && cmd.Cmd().ProcessState != nil {
+				job.exitCode = cmd.Cmd().ProcessState.ExitCode()
+			}
+		}
+		if job.exitCode == 0 || killed {
+			return
+		}
+	}
+}
+
+// finish prints the output of the job as a whole, so the output lines of jobs never interleave.
+// In keep order mode, the output is held until all previous jobs are printed.
+func (s *parallel) finish(job *parallelJob) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.args.keepOrder {
+		s.print(job)
+		return
+	}
+	s.done[job.seq] = job
+	for {
+		j, ok := s.done[s.next+1]
+		if !ok {
+			return
+		}
+		delete(s.done, j.seq)
+		s.next++
+		s.print(j)
+	}
+}
+
+func (s *parallel) print(job *parallelJob) {
+	if len(job.output) == 0 {
+		return
+	}
+	w := os.Stdout
+	if !s.args.tag {
+		w.Write(job.output)
+		if !bytes.HasSuffix(job.output, []byte("\n")) {
+			fmt.Fprintln(w)
+		}
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(job.output))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		fmt.Fprintf(w, "%s\t%s\n", job.input, scanner.Text())
+	}
+}
+
+// expandTemplate replaces the placeholders in the command template, the input is appended if no placeholder found.
+//
+//	{}  the input
+//	{.} the input without extension
+//	{/} the basename of input
+//	{//} the directory of input
+//	{#} the sequence number of the job, starts from 1
+func expandTemplate(template, input string, seq int) string {
+	if !strings.Contains(template, "{}") && !strings.Contains(template, "{.}") &&
+		!strings.Contains(template, "{/}") && !strings.Contains(template, "{//}") {
+		template += " {}"
+	}
+	return strings.NewReplacer(
+		"{}", shellQuote(input),
+		"{.}", shellQuote(strings.TrimSuffix(input, filepath.Ext(input))),
+		"{//}", shellQuote(filepath.Dir(input)),
+		"{/}", shellQuote(filepath.Base(input)),
+		"{#}", fmt.Sprintf("%d", seq),
+	).Replace(template)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func readInputs(r io.Reader) (inputs []string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			inputs = append(inputs, line)
+		}
+	}
+	return inputs, scanner.Err()
+}
+
+func printParallelSummary(total int, failed []*parallelJob) {
+	sort.Slice(failed, func(i, j int) bool { return failed[i].seq < failed[j].seq })
+	fmt.Fprintf(os.Stderr, "\n%d jobs, %d succeeded, %d failed\n", total, total-len(failed), len(failed))
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%-6s %-6s %-8s %s\n", "SEQ", "EXIT", "ATTEMPTS", "INPUT")
+	for _, job := range failed {
+		fmt.Fprintf(os.Stderr, "%-6d %-6d %-8d %s\n", job.seq, job.exitCode, job.attempts, job.input)
+	}
+}
+
+func parallelCommand() *cobra.Command {
+	cmdParallel := &cobra.Command{
+		Use: "parallel <command> [input...]",
+		Long: `exec the command for each input in parallel, inputs are the arguments after the command,
+or the lines read from stdin if no input argument, such as: gmct exec parallel -j 8 "gzip {}" < list.txt
+placeholders in command: {} input, {.} input without extension, {/} basename of input, {//} directory of input, {#} job sequence number.
+the input is appended to the command if no placeholder found. the exit code is 1 if any job failed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, a []string) error {
+			jobs, _ := c.Flags().GetInt("jobs")
+			timeout, _ := c.Flags().GetInt("timeout")
+			retry, _ := c.Flags().GetInt("retry")
+			keepOrder, _ := c.Flags().GetBool("keep-order")
+			tag, _ := c.Flags().GetBool("tag")
+			args := parallelArgs{
+				template:  a[0],
+				inputs:    a[1:],
+				jobs:      gcond.Cond(jobs <= 0, runtime.NumCPU(), jobs).Int(),
+				timeout:   time.Second * time.Duration(timeout),
+				retry:     gcond.Cond(retry < 0, 0, retry).Int(),
+				keepOrder: keepOrder,
+				tag:       tag,
+			}
+			if len(args.inputs) == 0 {
+				var err error
+				args.inputs, err = readInputs(os.Stdin)
+				if err != nil {
+					return err
+				}
+			}
+			if len(args.inputs) == 0 {
+				return fmt.Errorf("no input found")
+			}
+			failed := newParallel(args).Start()
+			printParallelSummary(len(args.inputs), failed)
+			if len(failed) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+	cmdParallel.Flags().IntP("jobs", "j", 0, "number of jobs to run at the same time, default: number of CPUs")
+	cmdParallel.Flags().IntP("timeout", "t", 0, "timeout seconds of each job, exceeded will be kill. 0: unlimited")
+	cmdParallel.Flags().IntP("retry", "r", 0, "retry times of failed job")
+	cmdParallel.Flags().BoolP("keep-order", "k", false, "print output in the order of inputs")
+	cmdParallel.Flags().Bool("tag", false, "prefix each output line with the input and a tab")
+	return cmdParallel
+}
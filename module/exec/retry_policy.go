@@ -0,0 +1,99 @@
+package exec
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	backoffFixed       = "fixed"
+	backoffExponential = "exponential"
+)
+
+// retryPolicy decides whether to restart the exited command and how long to sleep before restarting.
+type retryPolicy struct {
+	sleep       time.Duration
+	maxSleep    time.Duration
+	exponential bool
+	jitter      bool
+	// retryOn only retries on these exit codes if it is not empty.
+	retryOn []int
+	// noRetryOn never retries on these exit codes.
+	noRetryOn []int
+}
+
+func newRetryPolicy(sleep, maxSleep time.Duration, backoff string, jitter bool, retryOn, noRetryOn []int) (*retryPolicy, error) {
+	if backoff != backoffFixed && backoff != backoffExponential {
+		return nil, fmt.Errorf("unknown backoff: %s, should be one of: %s, %s", backoff, backoffFixed, backoffExponential)
+	}
+	for _, v := range retryOn {
+		if containsCode(noRetryOn, v) {
+			return nil, fmt.Errorf("exit code %d is in both --retry-on and --no-retry-on", v)
+		}
+	}
+	if maxSleep > 0 && maxSleep < sleep {
+		maxSleep = sleep
+	}
+	return &retryPolicy{
+		sleep:       sleep,
+		maxSleep:    maxSleep,
+		exponential: backoff == backoffExponential,
+		jitter:      jitter,
+		retryOn:     retryOn,
+		noRetryOn:   noRetryOn,
+	}, nil
+}
+
+func (s *retryPolicy) shouldRetry(exitCode int) bool {
+	if containsCode(s.noRetryOn, exitCode) {
+		return false
+	}
+	if len(s.retryOn) > 0 {
+		return containsCode(s.retryOn, exitCode)
+	}
+	return true
+}
+
+// delay returns the sleep duration before the next attempt, attempt starts from 1.
+// In exponential mode the sleep doubles on each attempt and is limited by maxSleep,
+// jitter picks a random duration between half and full of the computed sleep.
+func (s *retryPolicy) delay(attempt int) time.Duration {
+	d := s.sleep
+	if s.exponential {
+		for i := 1; i < attempt; i++ {
+			if s.maxSleep > 0 && d >= s.maxSleep || d > math.MaxInt64/2 {
+				break
+			}
+			d *= 2
+		}
+	}
+	if s.maxSleep > 0 && d > s.maxSleep {
+		d = s.maxSleep
+	}
+	if s.jitter && d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, v := range codes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCodeOf returns the exit code of the process, it is 128+signal if the process is killed by a signal,
+// like the shell does, such as 137 for SIGKILL.
+func exitCodeOf(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
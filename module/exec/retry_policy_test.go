@@ -0,0 +1,61 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	assert := assert.New(t)
+	for _, v := range []struct {
+		retryOn, noRetryOn []int
+		code               int
+		retry              bool
+	}{
+		{nil, nil, 1, true},
+		{nil, nil, 137, true},
+		{[]int{1, 137}, nil, 137, true},
+		{[]int{1, 137}, nil, 2, false},
+		{nil, []int{2}, 2, false},
+		{nil, []int{2}, 143, true},
+	} {
+		p, err := newRetryPolicy(time.Second, 0, backoffFixed, false, v.retryOn, v.noRetryOn)
+		assert.NoError(err)
+		assert.Equal(v.retry, p.shouldRetry(v.code), "retry on %v, no retry on %v, code %d", v.retryOn, v.noRetryOn, v.code)
+	}
+	_, err := newRetryPolicy(time.Second, 0, backoffFixed, false, []int{1}, []int{1})
+	assert.Error(err)
+	_, err = newRetryPolicy(time.Second, 0, "linear", false, nil, nil)
+	assert.Error(err)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	assert := assert.New(t)
+	for _, v := range []struct {
+		sleep, maxSleep time.Duration
+		backoff         string
+		attempt         int
+		delay           time.Duration
+	}{
+		{time.Second, 0, backoffFixed, 5, time.Second},
+		{time.Second, time.Minute, backoffExponential, 1, time.Second},
+		{time.Second, time.Minute, backoffExponential, 4, 8 * time.Second},
+		{time.Second, 10 * time.Second, backoffExponential, 10, 10 * time.Second},
+		// max sleep less than sleep is raised to sleep.
+		{5 * time.Second, time.Second, backoffExponential, 3, 5 * time.Second},
+	} {
+		p, err := newRetryPolicy(v.sleep, v.maxSleep, v.backoff, false, nil, nil)
+		assert.NoError(err)
+		assert.Equal(v.delay, p.delay(v.attempt), "%s attempt %d", v.backoff, v.attempt)
+	}
+	// no overflow without max sleep.
+	p, _ := newRetryPolicy(time.Second, 0, backoffExponential, false, nil, nil)
+	assert.True(p.delay(1000) > 0)
+	p, _ = newRetryPolicy(10*time.Second, 0, backoffFixed, true, nil, nil)
+	for i := 0; i < 100; i++ {
+		d := p.delay(1)
+		assert.True(d >= 5*time.Second && d <= 10*time.Second, d.String())
+	}
+}
@@ -0,0 +1,75 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotateWriter appends to the file, the file is rotated when its size exceeds maxSize,
+// the rotated files are file.1, file.2 ... file.N, N is maxBackups.
+type rotateWriter struct {
+	file       string
+	maxSize    int64
+	maxBackups int
+	lock       sync.Mutex
+	f          *os.File
+	size       int64
+}
+
+func newRotateWriter(file string, maxSize int64, maxBackups int) (w *rotateWriter, err error) {
+	w = &rotateWriter{file: file, maxSize: maxSize, maxBackups: maxBackups}
+	if err = w.open(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (s *rotateWriter) open() (err error) {
+	s.f, err = os.OpenFile(s.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	st, err := s.f.Stat()
+	if err != nil {
+		s.f.Close()
+		return
+	}
+	s.size = st.Size()
+	return
+}
+
+func (s *rotateWriter) Write(p []byte) (n int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(p)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = s.f.Write(p)
+	s.size += int64(n)
+	return
+}
+
+func (s *rotateWriter) rotate() (err error) {
+	s.f.Close()
+	if s.maxBackups <= 0 {
+		os.Remove(s.file)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", s.file, s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.file, i), fmt.Sprintf("%s.%d", s.file, i+1))
+		}
+		if err = os.Rename(s.file, s.file+".1"); err != nil {
+			return
+		}
+	}
+	return s.open()
+}
+
+func (s *rotateWriter) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.f.Close()
+}
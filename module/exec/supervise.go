@@ -0,0 +1,638 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/snail007/gmc"
+	gcore "github.com/snail007/gmc/core"
+	glog "github.com/snail007/gmc/module/log"
+	gexec "github.com/snail007/gmc/util/exec"
+	gfile "github.com/snail007/gmc/util/file"
+	gmchook "github.com/snail007/gmc/util/process/hook"
+	"github.com/spf13/cobra"
+)
+
+const (
+	restartAlways    = "always"
+	restartOnFailure = "on-failure"
+	restartNever     = "never"
+
+	stateRunning = "running"
+	stateBackoff = "backoff"
+	stateStopped = "stopped"
+	stateExited  = "exited"
+	stateFatal   = "fatal"
+
+	// resetRestartsAfter resets the backoff of a program if it has been running for such long time.
+	resetRestartsAfter = time.Minute
+)
+
+type programConfig struct {
+	name        string
+	command     string
+	workDir     string
+	env         map[string]string
+	restart     string
+	logFile     string
+	autoStart   bool
+	maxRestarts int
+	stopTimeout time.Duration
+	policy      *retryPolicy
+}
+
+// ProgramStatus is the status of a supervised program, it is returned by the supervisor over the socket.
+type ProgramStatus struct {
+	Name      string
+	State     string
+	Pid       int
+	StartTime time.Time
+	ExitCode  int
+	Restarts  int
+}
+
+type program struct {
+	cfg      *programConfig
+	lock     sync.Mutex
+	cmd      *gexec.Command
+	done     chan struct{}
+	state    string
+	pid      int
+	start    time.Time
+	exitCode int
+	restarts int
+	stopped  bool
+	signal   chan struct{}
+}
+
+func newProgram(cfg *programConfig) *program {
+	return &program{
+		cfg:     cfg,
+		state:   stateStopped,
+		stopped: !cfg.autoStart,
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+func (s *program) notify() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *program) status() ProgramStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return ProgramStatus{
+		Name:      s.cfg.name,
+		State:     s.state,
+		Pid:       s.pid,
+		StartTime: s.start,
+		ExitCode:  s.exitCode,
+		Restarts:  s.restarts,
+	}
+}
+
+func (s *program) setState(state string) {
+	s.lock.Lock()
+	s.state = state
+	s.lock.Unlock()
+}
+
+func (s *program) isStopped() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stopped
+}
+
+// loop keeps the program running according to its restart policy, it never returns.
+func (s *program) loop() {
+	attempt := 0
+	for {
+		for s.isStopped() {
+			// started again by user, restart the backoff.
+			attempt = 0
+			<-s.signal
+		}
+		start := time.Now()
+		exitCode, state, started, err := s.exec()
+		if s.isStopped() {
+			continue
+		}
+		if !started {
+			glog.Warnf("[%s] start fail, error: %s", s.cfg.name, err)
+		} else if state == nil {
+			glog.Infof("[%s] exited, error: %s", s.cfg.name, err)
+		} else if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			glog.Infof("[%s] killed by signal: %s, exit code: %d", s.cfg.name, status.Signal(), exitCode)
+		} else {
+			glog.Infof("[%s] exited with %d", s.cfg.name, exitCode)
+		}
+		if time.Since(start) >= resetRestartsAfter {
+			attempt = 0
+		}
+		switch {
+		case s.cfg.restart == restartNever,
+			s.cfg.restart == restartOnFailure && exitCode == 0,
+			!s.cfg.policy.shouldRetry(exitCode):
+			s.exitWithState(stateExited)
+			continue
+		case s.cfg.maxRestarts > 0 && attempt >= s.cfg.maxRestarts:
+			glog.Warnf("[%s] max restarts %d reached", s.cfg.name, s.cfg.maxRestarts)
+			s.exitWithState(stateFatal)
+			continue
+		}
+		attempt++
+		d := s.cfg.policy.delay(attempt)
+		s.lock.Lock()
+		s.state = stateBackoff
+		s.restarts++
+		s.lock.Unlock()
+		glog.Infof("[%s] restarting after %s...", s.cfg.name, d)
+		select {
+		case <-time.After(d):
+		case <-s.signal:
+		}
+	}
+}
+
+func (s *program) exitWithState(state string) {
+	s.lock.Lock()
+	s.state = state
+	s.stopped = true
+	s.lock.Unlock()
+}
+
+// exec runs the program and waits for it exited, started is false if the program can not be started,
+// exitCode is 128+signal if the program is killed by a signal, and -1 if the program state is unknown.
+func (s *program) exec() (exitCode int, state *os.ProcessState, started bool, err error) {
+	w, err := s.output()
+	if err != nil {
+		s.setState(stateFatal)
+		return -1, nil, false, err
+	}
+	defer func() {
+		if w != os.Stdout {
+			w.Close()
+		}
+	}()
+	done := make(chan struct{})
+	cmd := gexec.NewCommand(s.cfg.command).
+		Env(s.cfg.env).
+		WorkDir(s.cfg.workDir).
+		Output(w).
+		AfterExec(func(command *gexec.Command, c *exec.Cmd, err error) {
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.cmd = command
+			s.done = done
+			s.state = stateRunning
+			s.pid = c.Process.Pid
+			s.start = time.Now()
+			stopped := s.stopped
+			s.lock.Unlock()
+			glog.Infof("[%s] running pid: %d", s.cfg.name, c.Process.Pid)
+			if stopped {
+				// stop is requested while starting.
+				terminateCmd(c)
+			}
+		})
+	_, err = cmd.Exec()
+	exitCode = -1
+	if cmd.Cmd() != nil && cmd.Cmd().ProcessState != nil {
+		state = cmd.Cmd().ProcessState
+		exitCode = exitCodeOf(state)
+	}
+	s.lock.Lock()
+	s.cmd = nil
+	s.pid = 0
+	s.exitCode = exitCode
+	// done is set once the program is running, a program killed by signal is exited, not fatal.
+	started = s.done != nil
+	s.state = stateExited
+	if !started {
+		s.state = stateFatal
+	}
+	s.done = nil
+	s.lock.Unlock()
+	close(done)
+	return
+}
+
+func (s *program) output() (io.WriteCloser, error) {
+	if s.cfg.logFile == "" {
+		return os.Stdout, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.logFile), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(s.cfg.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// stopProcess sends SIGTERM to the program, and kills it if it is not exited after stop timeout.
+func (s *program) stopProcess() {
+	s.lock.Lock()
+	s.stopped = true
+	cmd, done := s.cmd, s.done
+	if cmd == nil {
+		s.state = stateStopped
+	}
+	s.lock.Unlock()
+	s.notify()
+	if cmd == nil {
+		return
+	}
+	glog.Infof("[%s] stopping pid: %d", s.cfg.name, cmd.Cmd().Process.Pid)
+	terminateCmd(cmd.Cmd())
+	select {
+	case <-done:
+	case <-time.After(s.cfg.stopTimeout):
+		glog.Warnf("[%s] not exited after %s, killing", s.cfg.name, s.cfg.stopTimeout)
+		killCmd(cmd.Cmd())
+		<-done
+	}
+	s.setState(stateStopped)
+}
+
+func (s *program) startProcess() {
+	s.lock.Lock()
+	s.stopped = false
+	s.lock.Unlock()
+	s.notify()
+}
+
+type supervisor struct {
+	programs []*program
+	socket   string
+	listener net.Listener
+}
+
+// loadSupervisorConfig loads the programs from the toml file, relative paths in it are relative to the file.
+func loadSupervisorConfig(file string) (programs []*programConfig, socket string, err error) {
+	cfg, err := gmc.New.ConfigFile(file)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(gfile.Abs(file))
+	var relPath = func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	socket = relPath(cfg.GetString("socket"))
+	if socket == "" {
+		socket = strings.TrimSuffix(gfile.Abs(file), filepath.Ext(file)) + ".sock"
+	}
+	var items []map[string]interface{}
+	switch v := cfg.Get("program").(type) {
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for _, m := range v {
+			if mm, ok := m.(map[string]interface{}); ok {
+				items = append(items, mm)
+			}
+		}
+	}
+	if len(items) == 0 {
+		return nil, "", fmt.Errorf("no [[program]] found in %s", file)
+	}
+	names := map[string]bool{}
+	for _, m := range items {
+		c := gmc.New.Config()
+		c.SetDefault("restart", restartAlways)
+		c.SetDefault("autostart", true)
+		c.SetDefault("sleep", 3)
+		c.SetDefault("max_sleep", "5m")
+		c.SetDefault("backoff", backoffFixed)
+		c.SetDefault("stop_timeout", 10)
+		if err = c.MergeConfigMap(m); err != nil {
+			return
+		}
+		p, e := newProgramConfig(c, relPath)
+		if e != nil {
+			return nil, "", e
+		}
+		if names[p.name] {
+			return nil, "", fmt.Errorf("duplicate program name: %s", p.name)
+		}
+		names[p.name] = true
+		programs = append(programs, p)
+	}
+	return
+}
+
+func newProgramConfig(c gcore.Config, relPath func(string) string) (p *programConfig, err error) {
+	p = &programConfig{
+		name:        c.GetString("name"),
+		command:     c.GetString("command"),
+		workDir:     relPath(c.GetString("workdir")),
+		env:         map[string]string{},
+		restart:     c.GetString("restart"),
+		logFile:     relPath(c.GetString("log")),
+		autoStart:   c.GetBool("autostart"),
+		maxRestarts: c.GetInt("max_restarts"),
+		stopTimeout: time.Second * time.Duration(c.GetInt("stop_timeout")),
+	}
+	if p.name == "" || strings.ContainsAny(p.name, " \t") || p.name == "all" {
+		return nil, fmt.Errorf("invalid program name: [%s]", p.name)
+	}
+	if p.command == "" {
+		return nil, fmt.Errorf("[%s] command is required", p.name)
+	}
+	if p.workDir == "" {
+		p.workDir = relPath(".")
+	}
+	switch p.restart {
+	case restartAlways, restartOnFailure, restartNever:
+	default:
+		return nil, fmt.Errorf("[%s] unknown restart: %s, should be one of: %s, %s, %s",
+			p.name, p.restart, restartAlways, restartOnFailure, restartNever)
+	}
+	for _, v := range c.GetStringSlice("env") {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("[%s] invalid env: %s, should be KEY=VALUE", p.name, v)
+		}
+		p.env[kv[0]] = kv[1]
+	}
+	p.policy, err = newRetryPolicy(time.Second*time.Duration(c.GetInt("sleep")), c.GetDuration("max_sleep"),
+		c.GetString("backoff"), false, c.GetIntSlice("retry_on"), c.GetIntSlice("no_retry_on"))
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %s", p.name, err)
+	}
+	return
+}
+
+func newSupervisor(file string) (s *supervisor, err error) {
+	cfgs, socket, err := loadSupervisorConfig(file)
+	if err != nil {
+		return
+	}
+	s = &supervisor{socket: socket}
+	for _, c := range cfgs {
+		s.programs = append(s.programs, newProgram(c))
+	}
+	return
+}
+
+func (s *supervisor) Start() (err error) {
+	if gfile.Exists(s.socket) {
+		if c, e := net.Dial("unix", s.socket); e == nil {
+			c.Close()
+			return fmt.Errorf("supervisor is already running on %s", s.socket)
+		}
+		os.Remove(s.socket)
+	}
+	srv := rpc.NewServer()
+	if err = srv.RegisterName("Supervisor", &supervisorRPC{s: s}); err != nil {
+		return
+	}
+	s.listener, err = net.Listen("unix", s.socket)
+	if err != nil {
+		return
+	}
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn)
+		}
+	}()
+	glog.Infof("supervisor listening on %s", s.socket)
+	for _, p := range s.programs {
+		go p.loop()
+	}
+	return
+}
+
+// Stop stops all programs and closes the socket.
+func (s *supervisor) Stop() {
+	s.listener.Close()
+	g := sync.WaitGroup{}
+	for _, p := range s.programs {
+		g.Add(1)
+		go func(p *program) {
+			defer g.Done()
+			p.stopProcess()
+		}(p)
+	}
+	g.Wait()
+	os.Remove(s.socket)
+}
+
+// find returns the programs by name, all programs are returned if name is empty or all.
+func (s *supervisor) find(name string) ([]*program, error) {
+	if name == "" || name == "all" {
+		return s.programs, nil
+	}
+	for _, p := range s.programs {
+		if p.cfg.name == name {
+			return []*program{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("program not found: %s", name)
+}
+
+// supervisorRPC is the service serves the control commands over the unix socket.
+type supervisorRPC struct {
+	s *supervisor
+}
+
+func (s *supervisorRPC) do(name string, reply *[]ProgramStatus, f func(p *program)) error {
+	programs, err := s.s.find(name)
+	if err != nil {
+		return err
+	}
+	if f != nil {
+		g := sync.WaitGroup{}
+		for _, p := range programs {
+			g.Add(1)
+			go func(p *program) {
+				defer g.Done()
+				f(p)
+			}(p)
+		}
+		g.Wait()
+	}
+	for _, p := range programs {
+		*reply = append(*reply, p.status())
+	}
+	return nil
+}
+
+func (s *supervisorRPC) Status(name string, reply *[]ProgramStatus) error {
+	return s.do(name, reply, nil)
+}
+
+func (s *supervisorRPC) Start(name string, reply *[]ProgramStatus) error {
+	err := s.do(name, reply, func(p *program) {
+		p.startProcess()
+	})
+	if err != nil {
+		return err
+	}
+	// wait a moment, so the returned state is running.
+	time.Sleep(time.Millisecond * 500)
+	*reply = nil
+	return s.do(name, reply, nil)
+}
+
+func (s *supervisorRPC) Stop(name string, reply *[]ProgramStatus) error {
+	return s.do(name, reply, func(p *program) {
+		p.stopProcess()
+	})
+}
+
+func (s *supervisorRPC) Restart(name string, reply *[]ProgramStatus) error {
+	err := s.do(name, reply, func(p *program) {
+		p.stopProcess()
+	})
+	if err != nil {
+		return err
+	}
+	*reply = nil
+	return s.Start(name, reply)
+}
+
+// callSupervisor calls the method of supervisor over the unix socket and prints the status of programs.
+func callSupervisor(socket, method, name string) (err error) {
+	client, err := rpc.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("connect to supervisor fail, is it running? error: %s", err)
+	}
+	defer client.Close()
+	var reply []ProgramStatus
+	if err = client.Call("Supervisor."+method, name, &reply); err != nil {
+		return
+	}
+	fmt.Printf("%-20s %-10s %-8s %-12s %-8s %s\n", "NAME", "STATE", "PID", "UPTIME", "RESTARTS", "EXIT")
+	for _, v := range reply {
+		pid, uptime, exitCode := "-", "-", "-"
+		if v.State == stateRunning {
+			pid = fmt.Sprintf("%d", v.Pid)
+			uptime = time.Since(v.StartTime).Truncate(time.Second).String()
+		} else if v.State != stateStopped && !v.StartTime.IsZero() {
+			exitCode = fmt.Sprintf("%d", v.ExitCode)
+		}
+		fmt.Printf("%-20s %-10s %-8s %-12s %-8d %s\n", v.Name, v.State, pid, uptime, v.Restarts, exitCode)
+	}
+	return
+}
+
+func superviseCommands() []*cobra.Command {
+	cmdSupervise := &cobra.Command{
+		Use: "supervise",
+		Long: `start the programs in the config file, and keep them running according to their restart policy.
+relative paths in the config file are relative to the directory of config file, config file example:
+
+# the unix socket to control the supervisor, default: procs.sock in the same directory of procs.toml
+socket = ""
+
+[[program]]
+name = "api"
+command = "go run ./cmd/api"
+workdir = "./api"
+env = ["APP_ENV=dev"]
+# always, on-failure or never
+restart = "always"
+# seconds to sleep before restarting, fixed or exponential backoff, max_sleep limits the exponential backoff
+sleep = 3
+backoff = "fixed"
+max_sleep = "5m"
+# 0 means no limit
+max_restarts = 0
+# only retry or never retry on these exit codes
+retry_on = []
+no_retry_on = []
+# stdout and stderr are appended to the log file, default: stdout of supervisor
+log = "logs/api.log"
+autostart = true
+# seconds to wait after SIGTERM, then the program will be killed
+stop_timeout = 10`,
+		RunE: func(c *cobra.Command, a []string) error {
+			cfgFile, _ := c.Flags().GetString("config")
+			s, err := newSupervisor(cfgFile)
+			if err != nil {
+				return err
+			}
+			if err = s.Start(); err != nil {
+				return err
+			}
+			gmchook.RegistShutdown(s.Stop)
+			gmchook.WaitShutdown()
+			return nil
+		},
+	}
+	cmdSupervise.Flags().StringP("config", "c", "procs.toml", "the config file of programs")
+	cmds := []*cobra.Command{cmdSupervise}
+	for _, v := range []struct {
+		method string
+		long   string
+	}{
+		{"Status", "show the status of the supervised program, all programs are shown if name is empty"},
+		{"Start", "start the supervised program, name all means all programs"},
+		{"Stop", "stop the supervised program, name all means all programs, or stop the daemon started by exec retry with --pid-file"},
+		{"Restart", "restart the supervised program, name all means all programs"},
+	} {
+		method := v.method
+		cmd := &cobra.Command{
+			Use:  strings.ToLower(method) + " [name]",
+			Long: v.long,
+			Args: cobra.MaximumNArgs(1),
+			RunE: func(c *cobra.Command, a []string) error {
+				if c.Flags().Lookup("pid-file") != nil {
+					if pidFile, _ := c.Flags().GetString("pid-file"); pidFile != "" {
+						timeout, _ := c.Flags().GetInt("timeout")
+						return stopDaemon(pidFile, time.Second*time.Duration(timeout))
+					}
+				}
+				name := ""
+				if len(a) > 0 {
+					name = a[0]
+				}
+				if name == "" && method != "Status" {
+					return fmt.Errorf("program name is required")
+				}
+				socket, err := supervisorSocket(c)
+				if err != nil {
+					return err
+				}
+				return callSupervisor(socket, method, name)
+			},
+		}
+		cmd.Flags().StringP("config", "c", "procs.toml", "the config file of programs, it is used to find the socket of supervisor")
+		cmd.Flags().String("socket", "", "the unix socket of supervisor, default: the socket in config file")
+		if method == "Stop" {
+			cmd.Flags().String("pid-file", "", "the pid file of exec retry, the process group in it will be stopped")
+			cmd.Flags().IntP("timeout", "t", 10, "seconds to wait after SIGTERM sent, then SIGKILL will be sent")
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+func supervisorSocket(c *cobra.Command) (socket string, err error) {
+	socket, _ = c.Flags().GetString("socket")
+	if socket != "" {
+		return
+	}
+	cfgFile, _ := c.Flags().GetString("config")
+	_, socket, err = loadSupervisorConfig(cfgFile)
+	return
+}
@@ -0,0 +1,165 @@
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	glog "github.com/snail007/gmc/module/log"
+	gexec "github.com/snail007/gmc/util/exec"
+	"github.com/snail007/gmct/util/filewatch"
+	"github.com/spf13/cobra"
+)
+
+type watchArgs struct {
+	cmdStr   string
+	matcher  *filewatch.Matcher
+	interval time.Duration
+	debounce time.Duration
+	noKill   bool
+}
+
+// watcher runs the command when the files changed, the previous run is killed before a new run started.
+type watcher struct {
+	args watchArgs
+	lock sync.Mutex
+	cmd  *gexec.Command
+	done chan struct{}
+}
+
+func (s *watcher) Start() {
+	scanner := filewatch.NewScanner(s.args.matcher)
+	scanner.NanoTime = true
+	var pending bool
+	var lastChange time.Time
+	first := true
+	for {
+		if scanner.Changed() {
+			pending = true
+			lastChange = time.Now()
+		}
+		// wait for no more changes in debounce duration, the first run starts immediately.
+		if pending && (first || time.Since(lastChange) >= s.args.debounce) {
+			pending = false
+			if !first {
+				glog.Info("file changes found, restarting...")
+			}
+			first = false
+			s.restart()
+		}
+		time.Sleep(s.args.interval)
+	}
+}
+
+func (s *watcher) restart() {
+	s.lock.Lock()
+	cmd, done := s.cmd, s.done
+	s.lock.Unlock()
+	if done != nil {
+		if !s.args.noKill && cmd != nil && cmd.Cmd() != nil && cmd.Cmd().Process != nil {
+			killCmd(cmd.Cmd())
+		}
+		<-done
+	}
+	done = make(chan struct{})
+	s.lock.Lock()
+	s.done = done
+	s.lock.Unlock()
+	go s.run(done)
+}
+
+func (s *watcher) run(done chan struct{}) {
+	defer close(done)
+	cmd := gexec.NewCommand(s.args.cmdStr).
+		BeforeExec(func(command *gexec.Command, c *exec.Cmd) {
+			c.Stdout = os.Stdout
+			c.Stderr = os.Stderr
+		}).
+		AfterExec(func(command *gexec.Command, c *exec.Cmd, err error) {
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.cmd = command
+			s.lock.Unlock()
+			glog.Infof("running pid: %d", c.Process.Pid)
+		})
+	_, err := cmd.Exec()
+	s.lock.Lock()
+	s.cmd = nil
+	s.lock.Unlock()
+	if cmd.Cmd() == nil || cmd.Cmd().ProcessState == nil {
+		glog.Warnf("exec fail, error: %s", err)
+		return
+	}
+	state := cmd.Cmd().ProcessState
+	if state.Exited() {
+		glog.Infof("process exited with %d, waiting for file changes", state.ExitCode())
+	}
+}
+
+func (s *watcher) Stop() {
+	s.lock.Lock()
+	cmd := s.cmd
+	s.lock.Unlock()
+	if cmd != nil && cmd.Cmd() != nil && cmd.Cmd().Process != nil {
+		killCmd(cmd.Cmd())
+	}
+}
+
+func watchCommand() *cobra.Command {
+	cmdWatch := &cobra.Command{
+		Use: "watch <command>",
+		Long: `exec the command, and re-run it when files changed, the previous run is killed before re-run,
+such as: gmct exec watch --dir . --ext .go "go test ./..."
+relative paths of --include, --exclude and --exclude-dir are relative to each of --dir, hidden files and directories are ignored.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, a []string) error {
+			dirs, _ := c.Flags().GetStringSlice("dir")
+			exts, _ := c.Flags().GetStringSlice("ext")
+			include, _ := c.Flags().GetStringSlice("include")
+			exclude, _ := c.Flags().GetStringSlice("exclude")
+			excludeDirs, _ := c.Flags().GetStringSlice("exclude-dir")
+			interval, _ := c.Flags().GetDuration("interval")
+			debounce, _ := c.Flags().GetDuration("debounce")
+			noKill, _ := c.Flags().GetBool("no-kill")
+			if interval <= 0 {
+				interval = time.Millisecond * 500
+			}
+			// all files are watched if no --ext given.
+			matcher := filewatch.NewMatcher(dirs, exts, include, exclude, excludeDirs)
+			matcher.AllExts = true
+			w := &watcher{args: watchArgs{
+				cmdStr:   a[0],
+				matcher:  matcher,
+				interval: interval,
+				debounce: debounce,
+				noKill:   noKill,
+			}}
+			signalChan := make(chan os.Signal, 1)
+			signal.Notify(signalChan,
+				os.Interrupt,
+				syscall.SIGHUP,
+				syscall.SIGINT,
+				syscall.SIGTERM,
+				syscall.SIGQUIT)
+			go w.Start()
+			sig := <-signalChan
+			w.Stop()
+			glog.Infof("exited with signal: %v", sig.String())
+			return nil
+		},
+	}
+	cmdWatch.Flags().StringSlice("dir", []string{"."}, "directories to watch, split by comma or repeat the option")
+	cmdWatch.Flags().StringSlice("ext", []string{".go"}, "file extensions to watch, empty means all files")
+	cmdWatch.Flags().StringSlice("include", nil, "files to watch even if its extension not in --ext")
+	cmdWatch.Flags().StringSlice("exclude", nil, "files not to watch")
+	cmdWatch.Flags().StringSlice("exclude-dir", []string{"vendor"}, "directories not to watch")
+	cmdWatch.Flags().Duration("interval", time.Millisecond*500, "interval of scanning files")
+	cmdWatch.Flags().Duration("debounce", time.Millisecond*300, "wait for no more changes in the duration before re-run")
+	cmdWatch.Flags().Bool("no-kill", false, "wait for the previous run exited instead of killing it")
+	return cmdWatch
+}
@@ -0,0 +1,312 @@
+package gotool
+
+import (
+	"bufio"
+	"fmt"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// goAPI is the standard library api and the go version added in, which is parsed from $GOROOT/api/go1.*.txt.
+// The key is the import path and symbol, such as: net/http.Client.CloseIdleConnections
+type goAPI struct {
+	symbols map[string]int
+}
+
+// goVersionMinor returns the minor version of go1.x, go1 is 0.
+func goVersionMinor(v string) (minor int, err error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(v, "go"), "v")
+	parts := strings.Split(s, ".")
+	if parts[0] != "1" {
+		return 0, fmt.Errorf("invalid go version: %s", v)
+	}
+	if len(parts) == 1 {
+		return 0, nil
+	}
+	return strconv.Atoi(parts[1])
+}
+
+func goVersionName(minor int) string {
+	if minor == 0 {
+		return "go1"
+	}
+	return fmt.Sprintf("go1.%d", minor)
+}
+
+func loadGoAPI() (api *goAPI, err error) {
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env GOROOT fail, error: %s", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(strings.TrimSpace(string(out)), "api", "go1*.txt"))
+	if len(files) == 0 {
+		return nil, fmt.Errorf("api files not found in %s", filepath.Join(strings.TrimSpace(string(out)), "api"))
+	}
+	api = &goAPI{symbols: map[string]int{}}
+	for _, file := range files {
+		minor, e := goVersionMinor(strings.TrimSuffix(filepath.Base(file), ".txt"))
+		if e != nil {
+			continue
+		}
+		if err = api.load(file, minor); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *goAPI) load(file string, minor int) (err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key := parseAPILine(scanner.Text()); key != "" {
+			if v, ok := s.symbols[key]; !ok || minor < v {
+				s.symbols[key] = minor
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseAPILine returns the key of api line, the lines of deprecation are ignored. line examples:
+//
+//	pkg net/http, method (*Client) CloseIdleConnections()
+//	pkg syscall (linux-386), const AF_INET = 2
+//	pkg go/ast, type File struct, GoVersion string
+//	pkg crypto/elliptic, type Curve interface, Add(*big.Int, *big.Int, *big.Int, *big.Int) (*big.Int, *big.Int)
+func parseAPILine(line string) string {
+	if !strings.HasPrefix(line, "pkg ") || strings.Contains(line, "//deprecated") {
+		return ""
+	}
+	line = strings.TrimPrefix(line, "pkg ")
+	i := strings.Index(line, ", ")
+	if i < 0 {
+		return ""
+	}
+	pkg := strings.Fields(line[:i])[0]
+	decl := line[i+2:]
+	name := func(s string) string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == '(' || r == '[' || r == ' ' })[0]
+	}
+	kind, rest, _ := strings.Cut(decl, " ")
+	switch kind {
+	case "func", "const", "var":
+		return pkg + "." + name(rest)
+	case "method":
+		// (*T[$0]) Name(...)
+		recv, method, _ := strings.Cut(rest, ") ")
+		recv = strings.TrimLeft(recv, "(*")
+		return pkg + "." + name(recv) + "." + name(method)
+	case "type":
+		typeName := name(rest)
+		if strings.Contains(rest, " {") {
+			// the summary of interface methods, such as: type FileInfo interface { IsDir, Name }
+			return pkg + "." + typeName
+		}
+		if parts := strings.SplitN(rest, ", ", 2); len(parts) == 2 {
+			// field of struct or method of interface, the name of embedded field is its type name,
+			// such as: embedded *io.Reader
+			member := name(parts[1])
+			if member == "embedded" {
+				embedded := strings.TrimLeft(strings.TrimPrefix(parts[1], "embedded "), "*")
+				member = name(embedded[strings.LastIndex(embedded, ".")+1:])
+			}
+			return pkg + "." + typeName + "." + member
+		}
+		return pkg + "." + typeName
+	}
+	return ""
+}
+
+// lookup returns the version of api, the api is import path and symbol, such as: net/http.Client.Do,
+// if api is a package, the version of the package is returned.
+func (s *goAPI) lookup(api string) (minor int, ok bool) {
+	if minor, ok = s.symbols[api]; ok {
+		return
+	}
+	// the package is added in the minimum version of its symbols.
+	minor = -1
+	for k, v := range s.symbols {
+		if strings.HasPrefix(k, api+".") && !strings.Contains(k[len(api)+1:], "/") && (minor < 0 || v < minor) {
+			minor = v
+		}
+	}
+	return minor, minor >= 0
+}
+
+// apiUsage is the standard library api used by the code.
+type apiUsage struct {
+	api      string
+	minor    int
+	position string
+}
+
+// symbolKey returns the api key of the object used in code, fields and methods use the named type recv.
+func symbolKey(obj types.Object, recv types.Type) string {
+	if obj.Pkg() == nil {
+		return ""
+	}
+	if recv == nil {
+		if obj.Parent() != obj.Pkg().Scope() {
+			return ""
+		}
+		return obj.Pkg().Path() + "." + obj.Name()
+	}
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+	return named.Obj().Pkg().Path() + "." + named.Obj().Name() + "." + obj.Name()
+}
+
+// usagesAbove returns the standard library apis used by the packages which are added after the go version minor.
+func (s *goAPI) usagesAbove(minor int, patterns ...string) (usages []*apiUsage, err error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedTypes | packages.NeedSyntax |
+			packages.NeedTypesInfo | packages.NeedDeps,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return
+	}
+	root, _ := os.Getwd()
+	found := map[string]*apiUsage{}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("load package %s fail, error: %s", pkg.PkgPath, pkg.Errors[0])
+		}
+		// the test main package is generated by go test in build cache, not the code of user.
+		if strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		for id, obj := range pkg.TypesInfo.Uses {
+			key := ""
+			if f, ok := obj.(*types.Func); ok && f.Type().(*types.Signature).Recv() != nil {
+				key = symbolKey(obj, f.Type().(*types.Signature).Recv().Type())
+			} else if v, ok := obj.(*types.Var); ok && v.IsField() {
+				// the owner of field is unknown here, the selections are checked below.
+				continue
+			} else {
+				key = symbolKey(obj, nil)
+			}
+			s.check(found, key, minor, pkg.Fset.Position(id.Pos()).String(), root)
+		}
+		for expr, sel := range pkg.TypesInfo.Selections {
+			if sel.Kind() != types.FieldVal {
+				continue
+			}
+			s.check(found, symbolKey(sel.Obj(), fieldOwner(sel)), minor, pkg.Fset.Position(expr.Sel.Pos()).String(), root)
+		}
+	}
+	for _, v := range found {
+		usages = append(usages, v)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].minor != usages[j].minor {
+			return usages[i].minor > usages[j].minor
+		}
+		return usages[i].api < usages[j].api
+	})
+	return
+}
+
+// fieldOwner returns the struct type which declares the field of selection, the field may be promoted from
+// an embedded struct.
+func fieldOwner(sel *types.Selection) types.Type {
+	t := sel.Recv()
+	index := sel.Index()
+	for _, i := range index[:len(index)-1] {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		t = st.Field(i).Type()
+	}
+	return t
+}
+
+func (s *goAPI) check(found map[string]*apiUsage, key string, minor int, position, root string) {
+	if key == "" {
+		return
+	}
+	v, ok := s.symbols[key]
+	if !ok || v <= minor {
+		return
+	}
+	// the member added with its type is skipped, the type is reported if it is used, otherwise the type may
+	// be used by an older alias, such as: os.FileInfo = fs.FileInfo.
+	if owner, ok := s.symbols[key[:strings.LastIndex(key, ".")]]; ok && owner == v {
+		return
+	}
+	if rel, err := filepath.Rel(root, position); err == nil && !strings.HasPrefix(rel, "..") {
+		position = rel
+	}
+	if u, ok := found[key]; !ok || position < u.position {
+		found[key] = &apiUsage{api: key, minor: v, position: position}
+	}
+}
+
+// runAPI prints the go version of api added in.
+func runAPI(api string) (err error) {
+	goAPI, err := loadGoAPI()
+	if err != nil {
+		return
+	}
+	minor, ok := goAPI.lookup(api)
+	if !ok {
+		return fmt.Errorf("%s not found in the api of go toolchain", api)
+	}
+	if minor == 0 {
+		fmt.Printf("%s added in all go version\n", api)
+	} else {
+		fmt.Printf("%s added in %s\n", api, goVersionName(minor))
+	}
+	return
+}
+
+// runAPIAbove prints the apis used by the packages added after the go version above, and the minimum go version
+// required by them, the packages of current directory are checked if patterns is empty.
+func runAPIAbove(above string, patterns []string) (err error) {
+	minor, err := goVersionMinor(above)
+	if err != nil {
+		return
+	}
+	goAPI, err := loadGoAPI()
+	if err != nil {
+		return
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	usages, err := goAPI.usagesAbove(minor, patterns...)
+	if err != nil {
+		return
+	}
+	if len(usages) == 0 {
+		fmt.Printf("no api added after %s is used\n", goVersionName(minor))
+		return
+	}
+	for _, u := range usages {
+		fmt.Printf("%s\t%s\t%s\n", goVersionName(u.minor), u.api, u.position)
+	}
+	fmt.Printf("minimum go version: %s\n", goVersionName(usages[0].minor))
+	return
+}
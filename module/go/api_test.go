@@ -0,0 +1,37 @@
+package gotool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAPILine(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("net/http.Client.CloseIdleConnections", parseAPILine("pkg net/http, method (*Client) CloseIdleConnections()"))
+	assert.Equal("sync/atomic.Pointer.Load", parseAPILine("pkg sync/atomic, method (*Pointer[$0]) Load() *$0 #47141"))
+	assert.Equal("syscall.AF_INET", parseAPILine("pkg syscall (linux-386), const AF_INET = 2"))
+	assert.Equal("go/ast.File.GoVersion", parseAPILine("pkg go/ast, type File struct, GoVersion string #59033"))
+	assert.Equal("os.FileInfo", parseAPILine("pkg os, type FileInfo interface { IsDir, ModTime, Mode, Name, Size, Sys }"))
+	assert.Equal("os.FileInfo.Size", parseAPILine("pkg os, type FileInfo interface, Size() int64"))
+	assert.Equal("slices.Contains", parseAPILine("pkg slices, func Contains[$0 interface{ ~[]$1 }, $1 comparable]($0, $1) bool #60091"))
+	assert.Equal("debug/macho.FatArch.File", parseAPILine("pkg debug/macho, type FatArch struct, embedded *File"))
+	assert.Equal("go/types.Checker.Info", parseAPILine("pkg go/types, type Checker struct, embedded *Info"))
+	assert.Equal("debug/plan9obj.Section.ReaderAt", parseAPILine("pkg debug/plan9obj, type Section struct, embedded io.ReaderAt"))
+	assert.Equal("", parseAPILine("pkg syscall (freebsd-arm64), func BpfBuflen //deprecated"))
+}
+
+func TestGoVersionMinor(t *testing.T) {
+	assert := assert.New(t)
+	minor, err := goVersionMinor("go1.18")
+	assert.NoError(err)
+	assert.Equal(18, minor)
+	minor, err = goVersionMinor("1.21.3")
+	assert.NoError(err)
+	assert.Equal(21, minor)
+	minor, err = goVersionMinor("go1")
+	assert.NoError(err)
+	assert.Equal(0, minor)
+	_, err = goVersionMinor("go2.1")
+	assert.Error(err)
+}
@@ -0,0 +1,278 @@
+package gotool
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/imports"
+)
+
+// formatter formats go files in process, the imports are sorted and grouped into stdlib, third-party and
+// the packages of localPrefix if imports is true.
+type formatter struct {
+	imports     bool
+	localPrefix string
+}
+
+func (s *formatter) format(file string, src []byte) ([]byte, error) {
+	// the simplifications of gofmt -s are applied first.
+	dst, err := simplifySource(file, src)
+	if err != nil || !s.imports {
+		return dst, err
+	}
+	imports.LocalPrefix = s.localPrefix
+	return imports.Process(file, dst, &imports.Options{
+		Comments:   true,
+		TabIndent:  true,
+		TabWidth:   8,
+		FormatOnly: true,
+	})
+}
+
+// goFiles returns the go files in dir, the vendor, testdata and the directories start with . or _ are skipped,
+// like the go tool does.
+func goFiles(dir string) (files []string, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") && !strings.HasPrefix(name, ".") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return
+}
+
+// unformatted is a file need formatting, src is the current content and dst is the formatted.
+type unformatted struct {
+	file     string
+	src, dst []byte
+}
+
+// check returns the go files in dir need formatting.
+func (s *formatter) check(dir string) (list []*unformatted, err error) {
+	files, err := goFiles(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		src, e := os.ReadFile(file)
+		if e != nil {
+			return nil, e
+		}
+		dst, e := s.format(file, src)
+		if e != nil {
+			return nil, fmt.Errorf("format %s fail, error: %s", file, e)
+		}
+		if !bytes.Equal(src, dst) {
+			list = append(list, &unformatted{file: file, src: src, dst: dst})
+		}
+	}
+	return
+}
+
+func splitLines(b []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// firstChangedLine returns the first line of src changed by formatting, start from 1.
+func (s *unformatted) firstChangedLine() int {
+	a, b := splitLines(s.src), splitLines(s.dst)
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i + 1
+}
+
+// maxDiffCells is the max size of lcs table, about 64MB, the changed lines are replaced entirely if exceeded.
+const maxDiffCells = 16 << 20
+
+// diff returns the unified diff of the file, the common lines of head and tail are trimmed first,
+// so the lcs is only computed on the changed part, the changes are split into hunks with 3 lines context.
+func (s *unformatted) diff() string {
+	a, b := splitLines(s.src), splitLines(s.dst)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	oldPart, newPart := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	var lines []string
+	for _, l := range a[:prefix] {
+		lines = append(lines, " "+l)
+	}
+	if (len(oldPart)+1)*(len(newPart)+1) > maxDiffCells {
+		for _, l := range oldPart {
+			lines = append(lines, "-"+l)
+		}
+		for _, l := range newPart {
+			lines = append(lines, "+"+l)
+		}
+	} else {
+		lines = append(lines, lineDiff(oldPart, newPart)...)
+	}
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, " "+l)
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", s.file, s.file)
+	writeHunks(buf, lines, 3)
+	return buf.String()
+}
+
+// writeHunks writes the diff lines in hunks, the changes separated by no more than 2*context lines are merged.
+func writeHunks(buf *bytes.Buffer, lines []string, context int) {
+	// oldLine[i] and newLine[i] are the count of old and new lines before lines[i].
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if l[0] != '+' {
+			oldLine[i+1]++
+		}
+		if l[0] != '-' {
+			newLine[i+1]++
+		}
+	}
+	start := func(line, count int) int {
+		if count == 0 {
+			return line
+		}
+		return line + 1
+	}
+	i := 0
+	for {
+		for i < len(lines) && lines[i][0] == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			return
+		}
+		end := i
+		for {
+			for end < len(lines) && lines[end][0] != ' ' {
+				end++
+			}
+			j := end
+			for j < len(lines) && lines[j][0] == ' ' {
+				j++
+			}
+			if j < len(lines) && j-end <= 2*context {
+				end = j
+				continue
+			}
+			break
+		}
+		from, to := i-context, end+context
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+		oldCount, newCount := oldLine[to]-oldLine[from], newLine[to]-newLine[from]
+		fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", start(oldLine[from], oldCount), oldCount, start(newLine[from], newCount), newCount)
+		for _, l := range lines[from:to] {
+			buf.WriteString(l + "\n")
+		}
+		i = end
+	}
+}
+
+// lineDiff returns the diff lines of a and b by the longest common subsequence, prefixed by space, - or +.
+func lineDiff(a, b []string) (lines []string) {
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	return
+}
+
+// modulePath returns the module path in go.mod of current directory, empty if not found.
+func modulePath() string {
+	b, err := os.ReadFile("go.mod")
+	if err != nil {
+		return ""
+	}
+	for _, line := range splitLines(b) {
+		if f := strings.Fields(line); len(f) >= 2 && f[0] == "module" {
+			return strings.Trim(f[1], `"`)
+		}
+	}
+	return ""
+}
+
+// runFmt formats the go files in current directory, the files are not written in check mode,
+// and the diffs are printed, it returns an error if any file need formatting.
+func runFmt(f *formatter, check bool) (err error) {
+	list, err := f.check(".")
+	if err != nil {
+		return
+	}
+	for _, v := range list {
+		if check {
+			fmt.Print(v.diff())
+			continue
+		}
+		info, e := os.Stat(v.file)
+		if e != nil {
+			return e
+		}
+		if err = os.WriteFile(v.file, v.dst, info.Mode().Perm()); err != nil {
+			return
+		}
+		fmt.Println(v.file)
+	}
+	if check && len(list) > 0 {
+		return fmt.Errorf("%d files need formatting", len(list))
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package gotool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnformattedDiff(t *testing.T) {
+	assert := assert.New(t)
+	src := "package a\n\nfunc a() {\nx := 1\n_ = x\n}\n"
+	dst, err := (&formatter{}).format("a.go", []byte(src))
+	assert.NoError(err)
+	u := &unformatted{file: "a.go", src: []byte(src), dst: dst}
+	assert.Equal(4, u.firstChangedLine())
+	assert.Equal("--- a.go\n+++ a.go\n@@ -1,6 +1,6 @@\n package a\n \n func a() {\n"+
+		"-x := 1\n-_ = x\n+\tx := 1\n+\t_ = x\n }\n", u.diff())
+}
+
+func TestFormatSimplify(t *testing.T) {
+	assert := assert.New(t)
+	src := "package a\n\nvar a = []T{T{1}, T{2}}\n\nfunc f(s []int) {\n\tfor _ = range s[1:len(s)] {\n\t}\n}\n"
+	dst, err := (&formatter{}).format("a.go", []byte(src))
+	assert.NoError(err)
+	assert.Equal("package a\n\nvar a = []T{{1}, {2}}\n\nfunc f(s []int) {\n\tfor range s[1:] {\n\t}\n}\n", string(dst))
+}
+
+func TestUnformattedDiffHunks(t *testing.T) {
+	assert := assert.New(t)
+	var src, dst string
+	for i := 0; i < 20; i++ {
+		src += fmt.Sprintf("line%d\n", i)
+		if i == 1 || i == 18 {
+			dst += "changed\n"
+			continue
+		}
+		dst += fmt.Sprintf("line%d\n", i)
+	}
+	u := &unformatted{file: "a.go", src: []byte(src), dst: []byte(dst)}
+	assert.Equal("--- a.go\n+++ a.go\n"+
+		"@@ -1,5 +1,5 @@\n line0\n-line1\n+changed\n line2\n line3\n line4\n"+
+		"@@ -16,5 +16,5 @@\n line15\n line16\n line17\n-line18\n+changed\n line19\n", u.diff())
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"github.com/PuerkitoBio/goquery"
 	glog "github.com/snail007/gmc/module/log"
 	gbatch "github.com/snail007/gmc/util/batch"
 	gcond "github.com/snail007/gmc/util/cond"
@@ -55,14 +54,9 @@ func init() {
 		goCMD := &cobra.Command{
 			Use: "go",
 			PersistentPreRunE: func(c *cobra.Command, a []string) error {
-				if (c.Name() == "install" || c.Name() == "api") && len(a) != 1 {
+				if c.Name() == "install" && len(a) != 1 {
 					return fmt.Errorf("arg required")
 				}
-				if c.Name() == "link" || c.Name() == "check" {
-					if !s.commandIsExists("golint") {
-						s.install("golang.org/x/lint/golint")
-					}
-				}
 				return nil
 			},
 		}
@@ -72,57 +66,120 @@ func init() {
 				return s.install(a[0])
 			},
 		})
-		goCMD.AddCommand(&cobra.Command{
-			Use: "api",
+		apiCMD := &cobra.Command{
+			Use:   "api",
+			Short: "show the go version of api added in, such as: net/http.Client.CloseIdleConnections",
+			Long: "show the go version of api added in, such as: net/http.Client.CloseIdleConnections,\n" +
+				"with --above, list the apis used by the packages added after the go version, such as: --above go1.18 ./...",
 			RunE: func(c *cobra.Command, a []string) error {
-				if !strings.Contains(a[0], ".") {
+				above, _ := c.Flags().GetString("above")
+				if above == "" && len(a) != 1 {
 					return fmt.Errorf("arg required")
 				}
-				s.api(a[0])
-				return nil
+				c.SilenceUsage = true
+				if above != "" {
+					return runAPIAbove(above, a)
+				}
+				return runAPI(a[0])
 			},
-		})
+		}
+		apiCMD.Flags().String("above", "", "list the apis used by the packages added after the go version, such as: go1.18")
+		goCMD.AddCommand(apiCMD)
 
-		goCMD.AddCommand(&cobra.Command{
+		lintCMD := &cobra.Command{
 			Use: "lint",
 			RunE: func(c *cobra.Command, a []string) error {
-				if !s.do(s.getLintCmd()) {
-					fmt.Println(goodCode)
-				}
-				return nil
+				format, _ := c.Flags().GetString("format")
+				return s.lint(c, &lintOptions{
+					analyzers: append(vetAnalyzers, lintAnalyzers...),
+					skip:      s.lintSkip(),
+					format:    format,
+				}, goodCode)
 			},
-		})
-
-		goCMD.AddCommand(&cobra.Command{
-			Use: "check",
+		}
+		checkCMD := &cobra.Command{
+			Use:  "check",
+			Long: "run lint and vet, and report the files need gofmt -s",
 			RunE: func(c *cobra.Command, args []string) error {
-				a := s.do(s.getLintCmd())
-				b := s.do(s.getVetCmd())
-				s.do(s.getFmtCmd())
-				if !a && !b {
-					fmt.Println(goodCode)
-				}
-				return nil
+				format, _ := c.Flags().GetString("format")
+				baseline, _ := c.Flags().GetString("baseline")
+				writeBaseline, _ := c.Flags().GetBool("write-baseline")
+				return s.lint(c, &lintOptions{
+					analyzers:     append(vetAnalyzers, lintAnalyzers...),
+					skip:          append(s.lintSkip(), s.vetSkip()...),
+					format:        format,
+					checkFmt:      true,
+					baseline:      baseline,
+					writeBaseline: writeBaseline,
+				}, goodCode)
 			},
-		})
-
-		goCMD.AddCommand(&cobra.Command{
+		}
+		vetCMD := &cobra.Command{
 			Use: "vet",
 			RunE: func(c *cobra.Command, a []string) error {
-				if !s.do(s.getVetCmd()) {
-					fmt.Println(goodCode)
+				format, _ := c.Flags().GetString("format")
+				return s.lint(c, &lintOptions{
+					analyzers: vetAnalyzers,
+					skip:      s.vetSkip(),
+					format:    format,
+				}, goodCode)
+			},
+		}
+		checkCMD.Flags().String("baseline", defaultBaselineFile, "the issues in the baseline file are not reported, set it to empty to report all issues")
+		checkCMD.Flags().Bool("write-baseline", false, "write current issues to the baseline file, then only new issues are reported")
+		for _, cmd := range []*cobra.Command{lintCMD, checkCMD, vetCMD} {
+			cmd.Flags().String("format", reportText, "output format of issues: "+strings.Join(reportFormats, ", "))
+			goCMD.AddCommand(cmd)
+		}
+
+		fmtCMD := &cobra.Command{
+			Use:  "fmt",
+			Long: "format and simplify the go files like gofmt -s, vendor and testdata are skipped",
+			RunE: func(c *cobra.Command, a []string) error {
+				check, _ := c.Flags().GetBool("check")
+				sortImports, _ := c.Flags().GetBool("imports")
+				local, _ := c.Flags().GetString("local")
+				if sortImports && local == "" {
+					local = modulePath()
 				}
-				return nil
+				c.SilenceUsage = true
+				return runFmt(&formatter{imports: sortImports, localPrefix: local}, check)
 			},
-		})
+		}
+		fmtCMD.Flags().Bool("check", false, "print the diffs of files need formatting and exit with non-zero code, files are not written")
+		fmtCMD.Flags().Bool("imports", false, "sort and group the imports into stdlib, third-party and local packages")
+		fmtCMD.Flags().String("local", "", "import path prefixes of local packages split by comma, default is the module path in go.mod")
+		goCMD.AddCommand(fmtCMD)
 
-		goCMD.AddCommand(&cobra.Command{
-			Use: "fmt",
+		modCMD := &cobra.Command{
+			Use:  "mod",
+			Long: "report and upgrade the dependencies of go.mod",
+		}
+		outdatedCMD := &cobra.Command{
+			Use:  "outdated",
+			Long: "list the dependencies have newer patch, minor or major versions",
 			RunE: func(c *cobra.Command, a []string) error {
-				s.do(s.getFmtCmd())
-				return nil
+				direct, _ := c.Flags().GetBool("direct")
+				c.SilenceUsage = true
+				return runModOutdated(direct)
 			},
-		})
+		}
+		upgradeCMD := &cobra.Command{
+			Use:  "upgrade",
+			Long: "select the dependencies to upgrade, then run go get and go mod tidy, and print the diff of go.mod",
+			RunE: func(c *cobra.Command, a []string) error {
+				direct, _ := c.Flags().GetBool("direct")
+				level, _ := c.Flags().GetString("level")
+				c.SilenceUsage = true
+				return runModUpgrade(direct, level)
+			},
+		}
+		for _, cmd := range []*cobra.Command{outdatedCMD, upgradeCMD} {
+			cmd.Flags().Bool("direct", false, "only the direct dependencies")
+			modCMD.AddCommand(cmd)
+		}
+		upgradeCMD.Flags().String("level", "", "upgrade all the dependencies to the latest patch or minor version without prompt, should be one of: patch, minor")
+		goCMD.AddCommand(modCMD)
 
 		pprofCMD := &cobra.Command{
 			Use: "pprof",
@@ -490,90 +547,29 @@ func NewGoTool() *GoTool {
 	return &GoTool{}
 }
 
-func (s *GoTool) getVetCmd() string {
-	vetSkipWords := []string{
-		"vendor/",
-		"should have signature",
-		"_test.go:",
-		"^# ",
-		"possible misuse of",
-	}
-	vetSkipWords = append(vetSkipWords, config.Options.GetStringSlice("go.vet.skip")...)
-	vetCmd := "go vet ./... 2>&1 "
-	for _, v := range vetSkipWords {
-		vetCmd += "| grep -v \"" + v + "\" "
-	}
-	return vetCmd
-}
-
-func (s *GoTool) getFmtCmd() string {
-	fmtCmd := `gofmt -s -w ./`
-	return fmtCmd
-}
-
-func (s *GoTool) getLintCmd() string {
-	lintSkipWords := []string{
-		"vendor/",
-		"receiver name should be a reflection of its identity",
-		"should have comment",
-		"string as key in context.WithValue",
-		"comment on exported function",
-		"don't use underscores in Go names",
-		"a blank import should be only in a main or test package",
-		"_test.go:",
-	}
-	lintSkipWords = append(lintSkipWords, config.Options.GetStringSlice("go.lint.skip")...)
-	lintCmd := "golint -min_confidence 0.9 ./... "
-	for _, v := range lintSkipWords {
-		lintCmd += "| grep -v \"" + v + "\" "
-	}
-	return lintCmd
-}
-
-func (s *GoTool) api(apiName string) {
-	info := strings.Split(apiName, ".")
-	pkg := info[0]
-	method := strings.Join(info[1:], ".")
-	queryURL := fmt.Sprintf("https://pkg.go.dev/%s", pkg)
-	client := ghttp.NewHTTPClient()
-	client.SetDNS("8.8.8.8:53")
-	client.SetProxyFromEnv(true)
-	d, _, err := client.Download(queryURL, time.Second*30, nil, nil)
-	if err != nil {
-		glog.Fatalf("fetch api info fail, maybe you need to set HTTP_PROXY=<PROXY_SERVER_URL> environment, access url error: %s", queryURL)
-	}
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(d))
-	if err != nil {
-		glog.Fatalf("parse api info fail, error: %s,\ncontent: %s", err, string(d))
-	}
-	sel := doc.Find("#" + strings.Replace(method, ".", `\.`, -1))
-	if len(sel.Nodes) == 0 {
-		glog.Warnf("not found %s info in url: %s", apiName, queryURL)
+// lint runs the analyzers and prints goodCode if no issue found in text format.
+func (s *GoTool) lint(c *cobra.Command, opts *lintOptions, goodCode string) (err error) {
+	if err = checkReportFormat(opts.format); err != nil {
 		return
 	}
-	exists := sel.Find(".Documentation-sinceVersionVersion")
-	if len(exists.Nodes) == 0 {
-		fmt.Println(apiName + " added in all go version")
+	c.SilenceUsage = true
+	if err = runLint(opts); err != nil {
 		return
 	}
-	label := sel.Find(".Documentation-sinceVersionLabel")
-	version := sel.Find(".Documentation-sinceVersionVersion")
-	fmt.Printf("%s %s %s\n", apiName, label.Text(), version.Text())
-}
-
-func (s *GoTool) do(c string) (hasOutput bool) {
-	cmd := exec.Command("bash", "-c", c)
-	b, _ := cmd.CombinedOutput()
-	if len(b) > 0 {
-		fmt.Print(strings.Trim(string(b), "\n \t"), "\n")
+	if opts.format == reportText && !opts.writeBaseline {
+		fmt.Println(goodCode)
 	}
-	hasOutput = len(bytes.Trim(b, "\r\n")) > 0
 	return
 }
 
-func (s *GoTool) commandIsExists(bin string) bool {
-	b, _ := exec.Command("which", bin).CombinedOutput()
-	return len(b) > 0
+// vetSkip returns the regexes of vet issues to ignore, config go.vet.skip is appended to the defaults.
+func (s *GoTool) vetSkip() []string {
+	return append(append([]string{}, defaultVetSkip...), config.Options.GetStringSlice("go.vet.skip")...)
+}
+
+// lintSkip returns the regexes of lint issues to ignore from config go.lint.skip.
+func (s *GoTool) lintSkip() []string {
+	return config.Options.GetStringSlice("go.lint.skip")
 }
 
 func (s *GoTool) install(pkg string) (err error) {
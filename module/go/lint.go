@@ -0,0 +1,475 @@
+package gotool
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/appends"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/buildtag"
+	"golang.org/x/tools/go/analysis/passes/cgocall"
+	"golang.org/x/tools/go/analysis/passes/composite"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/defers"
+	"golang.org/x/tools/go/analysis/passes/directive"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/ifaceassert"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/reflectvaluecompare"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/sigchanyzer"
+	"golang.org/x/tools/go/analysis/passes/slog"
+	"golang.org/x/tools/go/analysis/passes/sortslice"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/testinggoroutine"
+	"golang.org/x/tools/go/analysis/passes/tests"
+	"golang.org/x/tools/go/analysis/passes/timeformat"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+	"golang.org/x/tools/go/analysis/passes/unusedwrite"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+var (
+	// vetAnalyzers are the passes of go vet which not need the assembly files or the module information.
+	// Unlike go vet, the facts are not propagated from the dependencies, such as the printf wrappers declared
+	// in other packages are not checked by printf.
+	vetAnalyzers = []*analysis.Analyzer{
+		appends.Analyzer,
+		assign.Analyzer,
+		atomic.Analyzer,
+		bools.Analyzer,
+		buildtag.Analyzer,
+		cgocall.Analyzer,
+		composite.Analyzer,
+		copylock.Analyzer,
+		defers.Analyzer,
+		directive.Analyzer,
+		errorsas.Analyzer,
+		httpresponse.Analyzer,
+		ifaceassert.Analyzer,
+		loopclosure.Analyzer,
+		lostcancel.Analyzer,
+		nilfunc.Analyzer,
+		printf.Analyzer,
+		shift.Analyzer,
+		sigchanyzer.Analyzer,
+		slog.Analyzer,
+		stdmethods.Analyzer,
+		stringintconv.Analyzer,
+		structtag.Analyzer,
+		testinggoroutine.Analyzer,
+		tests.Analyzer,
+		timeformat.Analyzer,
+		unmarshal.Analyzer,
+		unreachable.Analyzer,
+		unsafeptr.Analyzer,
+		unusedresult.Analyzer,
+	}
+	// lintAnalyzers are the checks of bugs and code style beyond go vet.
+	lintAnalyzers = []*analysis.Analyzer{
+		deepequalerrors.Analyzer,
+		nilness.Analyzer,
+		reflectvaluecompare.Analyzer,
+		sortslice.Analyzer,
+		unusedwrite.Analyzer,
+		errorStringsAnalyzer,
+	}
+	defaultVetSkip = []string{
+		"should have signature",
+		"possible misuse of",
+	}
+)
+
+// errorStringsAnalyzer checks the error strings should not be capitalized or end with punctuation, like ST1005 of staticcheck.
+var errorStringsAnalyzer = &analysis.Analyzer{
+	Name:     "errorstrings",
+	Doc:      "check error strings are not capitalized and not end with punctuation or newlines",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+		ins.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+			call := n.(*ast.CallExpr)
+			fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+			if !ok || fn.Pkg() == nil || len(call.Args) == 0 {
+				return
+			}
+			if name := fn.Pkg().Path() + "." + fn.Name(); name != "errors.New" && name != "fmt.Errorf" {
+				return
+			}
+			tv, ok := pass.TypesInfo.Types[call.Args[0]]
+			if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+				return
+			}
+			if msg := checkErrorString(constant.StringVal(tv.Value)); msg != "" {
+				pass.Reportf(call.Args[0].Pos(), "%s", msg)
+			}
+		})
+		return nil, nil
+	},
+}
+
+func checkErrorString(s string) string {
+	if s == "" {
+		return ""
+	}
+	if strings.HasSuffix(s, "\n") {
+		return "error strings should not end with newlines"
+	}
+	if last, _ := utf8.DecodeLastRuneInString(s); strings.ContainsRune(".:!", last) {
+		return "error strings should not end with punctuation"
+	}
+	first, size := utf8.DecodeRuneInString(s)
+	if !unicode.IsUpper(first) {
+		return ""
+	}
+	// the first word may be an initialism or a name, such as: URL, ID or JSON.
+	word := strings.FieldsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) })[0]
+	if second, _ := utf8.DecodeRuneInString(s[size:]); len(word) > 1 && (unicode.IsUpper(second) || unicode.IsDigit(second)) {
+		return ""
+	}
+	return "error strings should not be capitalized"
+}
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// lintIssue is a problem found by an analyzer, the issues of go vet passes and type checking are errors,
+// and the others are warnings.
+type lintIssue struct {
+	Analyzer string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Message  string `json:"message"`
+}
+
+func (s *lintIssue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", s.File, s.Line, s.Column, s.Message, s.Analyzer)
+}
+
+// linter runs the analyzers in process, the issues matched by any of skip regexes are ignored.
+type linter struct {
+	analyzers []*analysis.Analyzer
+	skip      []*regexp.Regexp
+	root      string
+}
+
+func newLinter(analyzers []*analysis.Analyzer, skip []string) (l *linter, err error) {
+	l = &linter{analyzers: analyzers}
+	l.root, _ = os.Getwd()
+	for _, v := range skip {
+		if v == "" {
+			continue
+		}
+		r, e := regexp.Compile(v)
+		if e != nil {
+			return nil, fmt.Errorf("invalid skip regexp %q, error: %s", v, e)
+		}
+		l.skip = append(l.skip, r)
+	}
+	return
+}
+
+// run loads the packages matched by patterns and returns the issues sorted by position.
+// The packages can not be type checked are reported as typecheck issues and not analyzed.
+func (s *linter) run(patterns ...string) (issues []*lintIssue, err error) {
+	// the dependencies are type checked from source, because the export data of newer go may be unreadable.
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedTypes |
+			packages.NeedTypesSizes | packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedModule | packages.NeedDeps,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return
+	}
+	// the package is loaded twice with tests, the issues of the package and its test variant are merged by add.
+	for _, pkg := range pkgs {
+		// the test main package is generated by go test in build cache.
+		if strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		if len(pkg.Errors) > 0 {
+			for _, e := range pkg.Errors {
+				issues = s.add(issues, s.errorIssue(e))
+			}
+			continue
+		}
+		var pkgIssues []*lintIssue
+		pkgIssues, err = s.analyze(pkg)
+		if err != nil {
+			return
+		}
+		for _, issue := range pkgIssues {
+			issues = s.add(issues, issue)
+		}
+	}
+	sortIssues(issues)
+	return
+}
+
+// sortIssues sorts the issues by position.
+func sortIssues(issues []*lintIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+func (s *linter) add(issues []*lintIssue, issue *lintIssue) []*lintIssue {
+	str := issue.String()
+	for _, r := range s.skip {
+		if r.MatchString(str) {
+			return issues
+		}
+	}
+	for _, v := range issues {
+		if *v == *issue {
+			return issues
+		}
+	}
+	return append(issues, issue)
+}
+
+func (s *linter) relPath(file string) string {
+	if rel, err := filepath.Rel(s.root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+func (s *linter) errorIssue(e packages.Error) *lintIssue {
+	issue := &lintIssue{Analyzer: "typecheck", Severity: severityError, Message: e.Msg}
+	// position format: file:line:col or file:line or -
+	parts := strings.Split(e.Pos, ":")
+	issue.File = s.relPath(parts[0])
+	if len(parts) > 1 {
+		fmt.Sscan(parts[1], &issue.Line)
+	}
+	if len(parts) > 2 {
+		fmt.Sscan(parts[2], &issue.Column)
+	}
+	return issue
+}
+
+// analyze runs the analyzers and their required analyzers on the package. The facts are only shared in the
+// package, so the facts of dependencies, such as printf wrappers in other packages, are not available.
+func (s *linter) analyze(pkg *packages.Package) (issues []*lintIssue, err error) {
+	results := map[*analysis.Analyzer]interface{}{}
+	facts := map[factKey]analysis.Fact{}
+	var run func(a *analysis.Analyzer) error
+	run = func(a *analysis.Analyzer) error {
+		if _, ok := results[a]; ok {
+			return nil
+		}
+		resultOf := map[*analysis.Analyzer]interface{}{}
+		for _, req := range a.Requires {
+			if e := run(req); e != nil {
+				return e
+			}
+			resultOf[req] = results[req]
+		}
+		pass := &analysis.Pass{
+			Analyzer:     a,
+			Fset:         pkg.Fset,
+			Files:        pkg.Syntax,
+			OtherFiles:   pkg.OtherFiles,
+			IgnoredFiles: pkg.IgnoredFiles,
+			Pkg:          pkg.Types,
+			TypesInfo:    pkg.TypesInfo,
+			TypesSizes:   pkg.TypesSizes,
+			ResultOf:     resultOf,
+			ReadFile:     os.ReadFile,
+			Report: func(d analysis.Diagnostic) {
+				p := pkg.Fset.Position(d.Pos)
+				issues = append(issues, &lintIssue{
+					Analyzer: a.Name,
+					Severity: analyzerSeverity(a),
+					File:     s.relPath(p.Filename),
+					Line:     p.Line,
+					Column:   p.Column,
+					Message:  d.Message,
+				})
+			},
+			ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+				return importFact(facts, factKey{obj: obj, typ: reflect.TypeOf(fact)}, fact)
+			},
+			ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+				facts[factKey{obj: obj, typ: reflect.TypeOf(fact)}] = fact
+			},
+			ImportPackageFact: func(p *types.Package, fact analysis.Fact) bool {
+				return importFact(facts, factKey{pkg: p, typ: reflect.TypeOf(fact)}, fact)
+			},
+			ExportPackageFact: func(fact analysis.Fact) {
+				facts[factKey{pkg: pkg.Types, typ: reflect.TypeOf(fact)}] = fact
+			},
+			AllObjectFacts: func() (list []analysis.ObjectFact) {
+				for k, v := range facts {
+					if k.obj != nil {
+						list = append(list, analysis.ObjectFact{Object: k.obj, Fact: v})
+					}
+				}
+				return
+			},
+			AllPackageFacts: func() (list []analysis.PackageFact) {
+				for k, v := range facts {
+					if k.pkg != nil {
+						list = append(list, analysis.PackageFact{Package: k.pkg, Fact: v})
+					}
+				}
+				return
+			},
+		}
+		if pkg.Module != nil {
+			pass.Module = &analysis.Module{Path: pkg.Module.Path, Version: pkg.Module.Version, GoVersion: pkg.Module.GoVersion}
+		}
+		result, e := a.Run(pass)
+		if e != nil {
+			return fmt.Errorf("analyzer %s fail on package %s, error: %s", a.Name, pkg.PkgPath, e)
+		}
+		results[a] = result
+		return nil
+	}
+	for _, a := range s.analyzers {
+		if err = run(a); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type factKey struct {
+	obj types.Object
+	pkg *types.Package
+	typ reflect.Type
+}
+
+// importFact copies the fact stored in facts to fact, fact is a pointer.
+func importFact(facts map[factKey]analysis.Fact, key factKey, fact analysis.Fact) bool {
+	v, ok := facts[key]
+	if !ok {
+		return false
+	}
+	reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(v).Elem())
+	return true
+}
+
+func analyzerSeverity(a *analysis.Analyzer) string {
+	for _, v := range vetAnalyzers {
+		if v == a {
+			return severityError
+		}
+	}
+	return severityWarning
+}
+
+// rules returns the description of the analyzers and their required analyzers, the key is the name.
+func (s *linter) rules() map[string]string {
+	rules := map[string]string{"typecheck": "the package can not be type checked"}
+	var add func(list []*analysis.Analyzer)
+	add = func(list []*analysis.Analyzer) {
+		for _, a := range list {
+			rules[a.Name] = strings.SplitN(strings.TrimSpace(a.Doc), "\n", 2)[0]
+			add(a.Requires)
+		}
+	}
+	add(s.analyzers)
+	return rules
+}
+
+// lintOptions is the options of runLint, the issues in baseline file are not reported,
+// and all issues are written to the baseline file if writeBaseline is true.
+type lintOptions struct {
+	analyzers     []*analysis.Analyzer
+	skip          []string
+	format        string
+	checkFmt      bool
+	baseline      string
+	writeBaseline bool
+}
+
+// runLint runs the analyzers on the packages of current directory and prints the issues in format,
+// the files need gofmt are reported too if checkFmt is true. It returns an error if any issue found.
+func runLint(opts *lintOptions) (err error) {
+	l, err := newLinter(opts.analyzers, opts.skip)
+	if err != nil {
+		return
+	}
+	issues, err := l.run("./...")
+	if err != nil {
+		return
+	}
+	rules := l.rules()
+	if opts.checkFmt {
+		var fmtIssues []*lintIssue
+		fmtIssues, err = gofmtIssues(".")
+		if err != nil {
+			return
+		}
+		for _, issue := range fmtIssues {
+			issues = l.add(issues, issue)
+		}
+		rules[gofmtRule] = "the file is not formatted by gofmt"
+		sortIssues(issues)
+	}
+	if opts.writeBaseline {
+		if err = writeBaseline(opts.baseline, issues); err != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%d issues written to baseline %s\n", len(issues), opts.baseline)
+		return
+	}
+	if opts.baseline != "" {
+		var known int
+		issues, known, err = filterBaseline(opts.baseline, issues)
+		if err != nil {
+			return
+		}
+		if known > 0 {
+			fmt.Fprintf(os.Stderr, "%d issues in baseline %s are ignored\n", known, opts.baseline)
+		}
+	}
+	if err = writeReport(os.Stdout, opts.format, issues, rules); err != nil {
+		return
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issues found", len(issues))
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package gotool
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gfile "github.com/snail007/gmc/util/file"
+)
+
+const defaultBaselineFile = ".gmct/lint-baseline.json"
+
+// baselineIssue is a known issue, the line is not recorded, so the issue is still known after the code moved.
+// Count is the number of the same issues in the file, such as the same code repeated.
+type baselineIssue struct {
+	File    string `json:"file"`
+	Rule    string `json:"rule"`
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+type baselineKey struct {
+	file, rule, hash string
+}
+
+type lintBaseline struct {
+	Issues []*baselineIssue `json:"issues"`
+}
+
+var numberRegexp = regexp.MustCompile(`\d+`)
+
+// issueHash returns the hash of normalized message and the code of the issue line, the numbers in message are
+// ignored, because they may be line numbers, and the spaces of code are ignored.
+func issueHash(issue *lintIssue, lines func(file string) []string) string {
+	code := ""
+	if l := lines(issue.File); issue.Line > 0 && issue.Line <= len(l) {
+		code = strings.Join(strings.Fields(l[issue.Line-1]), " ")
+	}
+	h := sha256.Sum256([]byte(issue.Analyzer + "\n" + numberRegexp.ReplaceAllString(issue.Message, "N") + "\n" + code))
+	return hex.EncodeToString(h[:8])
+}
+
+// fileLines returns a function to read the lines of files, the files are read once.
+func fileLines() func(file string) []string {
+	cache := map[string][]string{}
+	return func(file string) []string {
+		if v, ok := cache[file]; ok {
+			return v
+		}
+		var lines []string
+		if f, err := os.Open(file); err == nil {
+			scanner := bufio.NewScanner(f)
+			scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			f.Close()
+		}
+		cache[file] = lines
+		return lines
+	}
+}
+
+func issueKey(issue *lintIssue, lines func(file string) []string) baselineKey {
+	return baselineKey{file: filepath.ToSlash(issue.File), rule: issue.Analyzer, hash: issueHash(issue, lines)}
+}
+
+// writeBaseline writes the issues to file, the same issues are merged with count.
+func writeBaseline(file string, issues []*lintIssue) (err error) {
+	lines := fileLines()
+	b := &lintBaseline{Issues: []*baselineIssue{}}
+	known := map[baselineKey]*baselineIssue{}
+	for _, issue := range issues {
+		k := issueKey(issue, lines)
+		if v, ok := known[k]; ok {
+			v.Count++
+			continue
+		}
+		v := &baselineIssue{File: k.file, Rule: k.rule, Hash: k.hash, Message: issue.Message, Count: 1}
+		known[k] = v
+		b.Issues = append(b.Issues, v)
+	}
+	sort.SliceStable(b.Issues, func(i, j int) bool {
+		if b.Issues[i].File != b.Issues[j].File {
+			return b.Issues[i].File < b.Issues[j].File
+		}
+		return b.Issues[i].Rule < b.Issues[j].Rule
+	})
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// filterBaseline returns the issues not in the baseline file, and the count of known issues.
+// All issues are new if the file not exists.
+func filterBaseline(file string, issues []*lintIssue) (newIssues []*lintIssue, known int, err error) {
+	if !gfile.Exists(file) {
+		return issues, 0, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	b := &lintBaseline{}
+	if err = json.Unmarshal(data, b); err != nil {
+		return
+	}
+	counts := map[baselineKey]int{}
+	for _, v := range b.Issues {
+		counts[baselineKey{file: v.File, rule: v.Rule, hash: v.Hash}] += v.Count
+	}
+	lines := fileLines()
+	for _, issue := range issues {
+		k := issueKey(issue, lines)
+		if counts[k] > 0 {
+			counts[k]--
+			known++
+			continue
+		}
+		newIssues = append(newIssues, issue)
+	}
+	return
+}
@@ -0,0 +1,233 @@
+package gotool
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	reportText       = "text"
+	reportJSON       = "json"
+	reportSARIF      = "sarif"
+	reportCheckstyle = "checkstyle"
+	reportGithub     = "github"
+
+	gofmtRule = "gofmt"
+)
+
+var reportFormats = []string{reportText, reportJSON, reportSARIF, reportCheckstyle, reportGithub}
+
+func checkReportFormat(format string) error {
+	for _, v := range reportFormats {
+		if v == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format: %s, should be one of: %s", format, strings.Join(reportFormats, ", "))
+}
+
+// writeReport writes the issues to w in format, rules is the description of rules, the key is the rule name.
+func writeReport(w io.Writer, format string, issues []*lintIssue, rules map[string]string) (err error) {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "", reportText:
+		for _, issue := range issues {
+			fmt.Fprintln(bw, issue.String())
+		}
+	case reportJSON:
+		if issues == nil {
+			issues = []*lintIssue{}
+		}
+		enc := json.NewEncoder(bw)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(issues)
+	case reportSARIF:
+		err = writeSARIF(bw, issues, rules)
+	case reportCheckstyle:
+		err = writeCheckstyle(bw, issues)
+	case reportGithub:
+		writeGithub(bw, issues)
+	default:
+		return checkReportFormat(format)
+	}
+	if err != nil {
+		return
+	}
+	return bw.Flush()
+}
+
+// writeGithub writes the issues as workflow commands of GitHub Actions, which are shown as annotations.
+func writeGithub(w io.Writer, issues []*lintIssue) {
+	escape := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	escapeProperty := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+	for _, issue := range issues {
+		fmt.Fprintf(w, "::%s file=%s,line=%d,col=%d,title=%s::%s\n", issue.Severity,
+			escapeProperty.Replace(filepath.ToSlash(issue.File)), issue.Line, issue.Column,
+			escapeProperty.Replace(issue.Analyzer), escape.Replace(issue.Message))
+	}
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name          `xml:"checkstyle"`
+	Version string            `xml:"version,attr"`
+	Files   []*checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string             `xml:"name,attr"`
+	Errors []*checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+func writeCheckstyle(w io.Writer, issues []*lintIssue) (err error) {
+	r := &checkstyleReport{Version: "5.0"}
+	files := map[string]*checkstyleFile{}
+	for _, issue := range issues {
+		f, ok := files[issue.File]
+		if !ok {
+			f = &checkstyleFile{Name: issue.File}
+			files[issue.File] = f
+			r.Files = append(r.Files, f)
+		}
+		f.Errors = append(f.Errors, &checkstyleError{
+			Line:     issue.Line,
+			Column:   issue.Column,
+			Severity: issue.Severity,
+			Message:  issue.Message,
+			Source:   issue.Analyzer,
+		})
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err = enc.Encode(r); err != nil {
+		return
+	}
+	_, err = io.WriteString(w, "\n")
+	return
+}
+
+type sarifReport struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool      `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string           `json:"ruleId"`
+	Level     string           `json:"level"`
+	Message   sarifMessage     `json:"message"`
+	Locations []*sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn,omitempty"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF writes the issues in SARIF 2.1.0, only the rules have issues are listed.
+func writeSARIF(w io.Writer, issues []*lintIssue, rules map[string]string) (err error) {
+	run := &sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "gmct",
+			InformationURI: "https://github.com/snail007/gmct",
+			Rules:          []*sarifRule{},
+		}},
+		Results: []*sarifResult{},
+	}
+	used := map[string]bool{}
+	for _, issue := range issues {
+		used[issue.Analyzer] = true
+		loc := &sarifLocation{}
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(issue.File)
+		// SARIF lines start at 1, the issues of a whole file have no line.
+		loc.PhysicalLocation.Region.StartLine = issue.Line
+		if loc.PhysicalLocation.Region.StartLine < 1 {
+			loc.PhysicalLocation.Region.StartLine = 1
+		}
+		loc.PhysicalLocation.Region.StartColumn = issue.Column
+		run.Results = append(run.Results, &sarifResult{
+			RuleID:    issue.Analyzer,
+			Level:     issue.Severity,
+			Message:   sarifMessage{Text: issue.Message},
+			Locations: []*sarifLocation{loc},
+		})
+	}
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, &sarifRule{ID: name, ShortDescription: sarifMessage{Text: rules[name]}})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&sarifReport{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []*sarifRun{run},
+	})
+}
+
+// gofmtIssues returns the go files in dir need gofmt, the line is the first line changed by gofmt.
+func gofmtIssues(dir string) (issues []*lintIssue, err error) {
+	list, err := (&formatter{}).check(dir)
+	if err != nil {
+		return
+	}
+	for _, v := range list {
+		issues = append(issues, &lintIssue{
+			Analyzer: gofmtRule,
+			Severity: severityWarning,
+			File:     v.file,
+			Line:     v.firstChangedLine(),
+			Message:  "file is not formatted by gofmt -s",
+		})
+	}
+	return
+}
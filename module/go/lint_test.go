@@ -0,0 +1,52 @@
+package gotool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckErrorString(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", checkErrorString("read file fail"))
+	assert.Equal("", checkErrorString("URL is invalid"))
+	assert.Equal("", checkErrorString("ID2 not found"))
+	assert.Equal("", checkErrorString(""))
+	assert.Equal("error strings should not be capitalized", checkErrorString("Read file fail"))
+	assert.Equal("error strings should not end with punctuation", checkErrorString("read file fail."))
+	assert.Equal("error strings should not end with newlines", checkErrorString("read file fail\n"))
+}
+
+func TestLinterSkip(t *testing.T) {
+	assert := assert.New(t)
+	_, err := newLinter(nil, []string{"("})
+	assert.Error(err)
+	l, err := newLinter(nil, []string{`_test\.go:`, `\(printf\)$`})
+	assert.NoError(err)
+	var issues []*lintIssue
+	issues = l.add(issues, &lintIssue{Analyzer: "printf", File: "a.go", Line: 1, Message: "x"})
+	issues = l.add(issues, &lintIssue{Analyzer: "nilness", File: "a_test.go", Line: 1, Message: "x"})
+	issues = l.add(issues, &lintIssue{Analyzer: "nilness", File: "a.go", Line: 2, Message: "x"})
+	issues = l.add(issues, &lintIssue{Analyzer: "nilness", File: "a.go", Line: 2, Message: "x"})
+	assert.Len(issues, 1)
+}
+
+func TestBaseline(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	baseline := filepath.Join(dir, "baseline.json")
+	os.WriteFile(src, []byte("package a\n\nvar a = 1\n"), 0644)
+	issue := &lintIssue{Analyzer: "x", File: src, Line: 3, Message: "bad at line 3"}
+	assert.NoError(writeBaseline(baseline, []*lintIssue{issue}))
+	// the line moved and the code not changed
+	os.WriteFile(src, []byte("package a\n\n// a\nvar a = 1\nvar b = 1\n"), 0644)
+	moved := &lintIssue{Analyzer: "x", File: src, Line: 4, Message: "bad at line 4"}
+	added := &lintIssue{Analyzer: "x", File: src, Line: 5, Message: "bad at line 5"}
+	issues, known, err := filterBaseline(baseline, []*lintIssue{moved, added})
+	assert.NoError(err)
+	assert.Equal(1, known)
+	assert.Equal([]*lintIssue{added}, issues)
+}
@@ -0,0 +1,378 @@
+package gotool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/snail007/gmc/util/gpool"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/term"
+)
+
+const (
+	upgradePatch = "patch"
+	upgradeMinor = "minor"
+	upgradeMajor = "major"
+)
+
+// goModule is the module info printed by go list -m -json.
+type goModule struct {
+	Path     string
+	Version  string
+	Main     bool
+	Indirect bool
+	Versions []string
+	Update   *goModule
+	Replace  *goModule
+	Error    *struct {
+		Err string
+	}
+}
+
+// moduleUpdate is the newer versions of a dependency, the version is empty if there is no newer one.
+// patch is the latest of the same minor version, minor is the latest of the same major version,
+// major is the latest of the higher major versions, which has a different module path majorPath,
+// except the +incompatible versions.
+type moduleUpdate struct {
+	path      string
+	current   string
+	indirect  bool
+	patch     string
+	minor     string
+	major     string
+	majorPath string
+}
+
+// listModules returns the dependencies of main module with all the versions known by the module proxy,
+// the replaced modules are skipped, they can not be upgraded by go get.
+func listModules() (list []*goModule, err error) {
+	c := exec.Command("go", "list", "-m", "-e", "-u", "-versions", "-json", "all")
+	buf := &bytes.Buffer{}
+	c.Stderr = buf
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list fail, error: %s, %s", err, strings.TrimSpace(buf.String()))
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		m := &goModule{}
+		if err = dec.Decode(m); err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if m.Main || m.Replace != nil {
+			continue
+		}
+		list = append(list, m)
+	}
+}
+
+// newModuleUpdate returns the newer versions of m in the versions known, the pre-release versions are ignored
+// unless the current version is a pre-release too.
+func newModuleUpdate(m *goModule) *moduleUpdate {
+	u := &moduleUpdate{path: m.Path, current: m.Version, indirect: m.Indirect}
+	versions := m.Versions
+	if m.Update != nil {
+		versions = append(versions, m.Update.Version)
+	}
+	for _, v := range versions {
+		if semver.Compare(v, m.Version) <= 0 || (semver.Prerelease(v) != "" && semver.Prerelease(m.Version) == "") {
+			continue
+		}
+		switch {
+		case semver.MajorMinor(v) == semver.MajorMinor(m.Version):
+			if semver.Compare(v, u.patch) > 0 {
+				u.patch = v
+			}
+		case semver.Major(v) == semver.Major(m.Version):
+			if semver.Compare(v, u.minor) > 0 {
+				u.minor = v
+			}
+		default:
+			// the +incompatible versions of higher major have the same module path.
+			if semver.Compare(v, u.major) > 0 {
+				u.major = v
+				u.majorPath = m.Path
+			}
+		}
+	}
+	return u
+}
+
+func (s *moduleUpdate) outdated() bool {
+	return s.patch != "" || s.minor != "" || s.major != ""
+}
+
+// nextMajorPath returns the module path of next major version of path, such as: example.com/a/v3 for
+// example.com/a/v2, and gopkg.in/yaml.v3 for gopkg.in/yaml.v2.
+func nextMajorPath(path, version string) (string, bool) {
+	prefix, pathMajor, ok := module.SplitPathVersion(path)
+	if !ok {
+		return "", false
+	}
+	n := 1
+	if pathMajor != "" {
+		n, _ = strconv.Atoi(strings.TrimLeft(pathMajor, "/.v"))
+	} else if strings.HasSuffix(version, "+incompatible") {
+		n, _ = strconv.Atoi(strings.TrimPrefix(semver.Major(version), "v"))
+	}
+	if strings.HasPrefix(path, "gopkg.in/") {
+		return fmt.Sprintf("%s.v%d", prefix, n+1), true
+	}
+	return fmt.Sprintf("%s/v%d", prefix, n+1), true
+}
+
+// latestVersion returns the latest version of module path from the module proxy, empty if path is not a module.
+func latestVersion(path string) string {
+	out, err := exec.Command("go", "list", "-m", "-json", path+"@latest").Output()
+	if err != nil {
+		return ""
+	}
+	m := &goModule{}
+	if json.Unmarshal(out, m) != nil || m.Error != nil {
+		return ""
+	}
+	return m.Version
+}
+
+// probeMajor finds the higher major versions of the module by the module path /vN+1, /vN+2 ...
+func (s *moduleUpdate) probeMajor() {
+	path, version := s.path, s.current
+	for {
+		next, ok := nextMajorPath(path, version)
+		if !ok {
+			return
+		}
+		v := latestVersion(next)
+		if v == "" {
+			return
+		}
+		s.major, s.majorPath = v, next
+		path, version = next, v
+	}
+}
+
+// outdatedModules returns the dependencies have newer versions, only the direct dependencies are returned
+// if direct is true, the higher major versions are probed concurrently.
+func outdatedModules(direct bool) (updates []*moduleUpdate, err error) {
+	list, err := listModules()
+	if err != nil {
+		return
+	}
+	var all []*moduleUpdate
+	p := gpool.New(8)
+	for _, m := range list {
+		if direct && m.Indirect {
+			continue
+		}
+		u := newModuleUpdate(m)
+		all = append(all, u)
+		p.Submit(u.probeMajor)
+	}
+	p.WaitDone()
+	p.Stop()
+	for _, u := range all {
+		if u.outdated() {
+			updates = append(updates, u)
+		}
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		if updates[i].indirect != updates[j].indirect {
+			return !updates[i].indirect
+		}
+		return updates[i].path < updates[j].path
+	})
+	return
+}
+
+// runModOutdated prints the dependencies have newer versions in table.
+func runModOutdated(direct bool) (err error) {
+	updates, err := outdatedModules(direct)
+	if err != nil {
+		return
+	}
+	if len(updates) == 0 {
+		fmt.Println("all dependencies are up to date")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tCURRENT\tPATCH\tMINOR\tMAJOR\tTYPE")
+	orNone := func(v string) string {
+		if v == "" {
+			return "-"
+		}
+		return v
+	}
+	for _, u := range updates {
+		major := orNone(u.major)
+		if u.majorPath != "" && u.majorPath != u.path {
+			major = u.majorPath + "@" + u.major
+		}
+		typ := "direct"
+		if u.indirect {
+			typ = "indirect"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", u.path, u.current, orNone(u.patch), orNone(u.minor), major, typ)
+	}
+	return w.Flush()
+}
+
+// upgradeOption is an upgrade can be selected, the major versions of different module path are not included,
+// because they need to change the import paths in code.
+type upgradeOption struct {
+	path    string
+	version string
+	level   string
+	text    string
+}
+
+func upgradeOptions(updates []*moduleUpdate) (options []*upgradeOption) {
+	for _, u := range updates {
+		major := u.major
+		if u.majorPath != u.path {
+			major = ""
+		}
+		for _, v := range []struct{ version, level string }{{u.patch, upgradePatch}, {u.minor, upgradeMinor}, {major, upgradeMajor}} {
+			if v.version == "" {
+				continue
+			}
+			text := fmt.Sprintf("%s %s -> %s (%s)", u.path, u.current, v.version, v.level)
+			if u.indirect {
+				text += " // indirect"
+			}
+			options = append(options, &upgradeOption{path: u.path, version: v.version, level: v.level, text: text})
+		}
+	}
+	return
+}
+
+// selectUpgrades returns the upgrades to apply, all the upgrades of level are selected if level is not empty,
+// otherwise the user selects them. The higher version is used if more than one version of a module selected.
+func selectUpgrades(options []*upgradeOption, level string) (selected map[string]string, err error) {
+	var picked []*upgradeOption
+	switch level {
+	case upgradePatch, upgradeMinor:
+		for _, o := range options {
+			// minor level includes the modules only have patch versions.
+			if o.level == upgradePatch || o.level == level {
+				picked = append(picked, o)
+			}
+		}
+	case "":
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, fmt.Errorf("stdin is not a terminal, use option --level to select the upgrades")
+		}
+		var texts []string
+		for _, o := range options {
+			texts = append(texts, o.text)
+		}
+		var qs = []*survey.Question{{
+			Name: "indexes",
+			Prompt: &survey.MultiSelect{
+				Message:  "which dependencies do you want to upgrade?",
+				Options:  texts,
+				PageSize: 20,
+			},
+		},
+		}
+		answers := struct {
+			Indexes []int
+		}{}
+		if err = survey.Ask(qs, &answers); err != nil {
+			return
+		}
+		for _, i := range answers.Indexes {
+			picked = append(picked, options[i])
+		}
+	default:
+		return nil, fmt.Errorf("unknown level: %s, should be one of: %s, %s", level, upgradePatch, upgradeMinor)
+	}
+	selected = map[string]string{}
+	for _, o := range picked {
+		if semver.Compare(o.version, selected[o.path]) > 0 {
+			selected[o.path] = o.version
+		}
+	}
+	return
+}
+
+func runGo(args ...string) error {
+	fmt.Println("go " + strings.Join(args, " "))
+	c := exec.Command("go", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// runModUpgrade upgrades the selected dependencies by go get, then runs go mod tidy and prints the diff of go.mod.
+func runModUpgrade(direct bool, level string) (err error) {
+	if level != "" && level != upgradePatch && level != upgradeMinor {
+		return fmt.Errorf("unknown level: %s, should be one of: %s, %s", level, upgradePatch, upgradeMinor)
+	}
+	updates, err := outdatedModules(direct)
+	if err != nil {
+		return
+	}
+	var majors []string
+	for _, u := range updates {
+		if u.major != "" && u.majorPath != u.path {
+			majors = append(majors, u.majorPath+"@"+u.major)
+		}
+	}
+	if len(majors) > 0 {
+		fmt.Printf("new major versions are skipped, the import paths need to be changed to upgrade them: %s\n", strings.Join(majors, ", "))
+	}
+	options := upgradeOptions(updates)
+	if len(options) == 0 {
+		fmt.Println("nothing to upgrade")
+		return
+	}
+	selected, err := selectUpgrades(options, level)
+	if err != nil {
+		return
+	}
+	if len(selected) == 0 {
+		fmt.Println("nothing selected")
+		return
+	}
+	var paths []string
+	for path := range selected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	args := []string{"get"}
+	for _, path := range paths {
+		args = append(args, path+"@"+selected[path])
+	}
+	src, err := os.ReadFile("go.mod")
+	if err != nil {
+		return
+	}
+	if err = runGo(args...); err != nil {
+		return
+	}
+	if err = runGo("mod", "tidy"); err != nil {
+		return
+	}
+	dst, err := os.ReadFile("go.mod")
+	if err != nil {
+		return
+	}
+	if bytes.Equal(src, dst) {
+		fmt.Println("go.mod not changed")
+		return
+	}
+	fmt.Print((&unformatted{file: "go.mod", src: src, dst: dst}).diff())
+	return
+}
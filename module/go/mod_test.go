@@ -0,0 +1,37 @@
+package gotool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewModuleUpdate(t *testing.T) {
+	assert := assert.New(t)
+	u := newModuleUpdate(&goModule{
+		Path:     "example.com/a",
+		Version:  "v1.2.3",
+		Versions: []string{"v1.0.0", "v1.2.3", "v1.2.4", "v1.2.5", "v1.3.0", "v1.4.0-rc.1", "v2.0.0+incompatible"},
+	})
+	assert.Equal("v1.2.5", u.patch)
+	assert.Equal("v1.3.0", u.minor)
+	assert.Equal("v2.0.0+incompatible", u.major)
+	assert.Equal("example.com/a", u.majorPath)
+	u = newModuleUpdate(&goModule{Path: "example.com/a", Version: "v1.3.0", Versions: []string{"v1.2.5", "v1.3.0"}})
+	assert.False(u.outdated())
+}
+
+func TestNextMajorPath(t *testing.T) {
+	assert := assert.New(t)
+	for path, next := range map[string]string{
+		"example.com/a":    "example.com/a/v2",
+		"example.com/a/v2": "example.com/a/v3",
+		"gopkg.in/yaml.v2": "gopkg.in/yaml.v3",
+	} {
+		v, ok := nextMajorPath(path, "v1.0.0")
+		assert.True(ok)
+		assert.Equal(next, v)
+	}
+	v, _ := nextMajorPath("example.com/a", "v3.1.0+incompatible")
+	assert.Equal("example.com/a/v4", v)
+}
@@ -1,26 +1,21 @@
 package gotool
 
 import (
-	"bytes"
 	"embed"
-	"encoding/base64"
 	"fmt"
 	"github.com/snail007/gmc"
 	gcore "github.com/snail007/gmc/core"
 	gtemplate "github.com/snail007/gmc/http/template"
 	gview "github.com/snail007/gmc/http/view"
 	gctx "github.com/snail007/gmc/module/ctx"
-	gcond "github.com/snail007/gmc/util/cond"
 	gfile "github.com/snail007/gmc/util/file"
 	gmap "github.com/snail007/gmc/util/map"
 	"github.com/snail007/gmct/module/go/static"
 	"github.com/snail007/gmct/module/go/template"
+	"github.com/snail007/gmct/util/embedfs"
 	gprofile "github.com/snail007/gmct/util/profile"
-	"io/fs"
 	"net/http"
-	"path/filepath"
 	"strings"
-	"time"
 )
 
 type PprofFiles struct {
@@ -156,14 +151,14 @@ func (s *PprofServer) init() (err error) {
 		return
 	}
 	s.tpl.DisableLoadDefaultBinData()
-	parseTemplateFromEmbedFS(s.tpl, s.tplFiles, "tpl/")
+	embedfs.ParseTemplate(s.tpl, s.tplFiles, "tpl/")
 	s.tpl.DdisableLogging()
 	err = s.tpl.Parse()
 	if err != nil {
 		return
 	}
 	//init static files
-	serveEmbedFS(s.server.Router(), s.staticFiles, "/static/")
+	embedfs.Serve(s.server.Router(), s.staticFiles, "/static/")
 
 	//init analyser ui
 	s.server.Router().HandlerFuncAny("/", func(w http.ResponseWriter, r *http.Request) {
@@ -278,36 +273,6 @@ func (s *PprofServer) Start() (err error) {
 	return s.server.Run()
 }
 
-func parseTemplateFromEmbedFS(tpl *gtemplate.Template, tplFiles embed.FS, trimPrefix string) {
-	bindData := map[string]string{}
-	fs.WalkDir(tplFiles, "tpl", func(path string, d fs.DirEntry, err error) error {
-		if !d.IsDir() && filepath.Ext(d.Name()) == ".html" {
-			b, _ := tplFiles.ReadFile(path)
-			key := gcond.Cond(trimPrefix == "", path, strings.TrimPrefix(path, "tpl/")).String()
-			key = strings.TrimSuffix(key, ".html")
-			bindData[key] = base64.StdEncoding.EncodeToString(b)
-		}
-		return nil
-	})
-	tpl.SetBinBase64(bindData)
-}
-
-func serveEmbedFS(router gcore.HTTPRouter, staticFiles embed.FS, pathPrefix string) {
-	pathPrefix = strings.TrimSuffix(pathPrefix, "/")
-	bindPath := pathPrefix + "/*filepath"
-	router.HandlerFuncAny(bindPath, func(w http.ResponseWriter, r *http.Request) {
-		ctx := gctx.NewCtxWithHTTP(w, r)
-		path := strings.TrimPrefix(ctx.Request().URL.Path, pathPrefix+"/")
-		b, err := staticFiles.ReadFile(path)
-		if err != nil {
-			ctx.Status(500)
-			ctx.Write(err)
-			return
-		}
-		http.ServeContent(w, r, filepath.Base(path), time.Time{}, bytes.NewReader(b))
-	})
-}
-
 type TopItemListInfo struct {
 	Total   string
 	Percent string
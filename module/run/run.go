@@ -7,6 +7,7 @@ import (
 	gmchook "github.com/snail007/gmc/util/process/hook"
 	"github.com/snail007/gmct/module/module"
 	"github.com/snail007/gmct/util"
+	"github.com/snail007/gmct/util/filewatch"
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	"os"
@@ -55,33 +56,25 @@ func init() {
 }
 
 type Run struct {
-	onlyExts     map[string]bool
-	runName      string
-	proc         *exec.Cmd
-	restartSig   chan bool
-	stopCtx      context.Context
-	cancle       context.CancelFunc
-	buildEnv     []string
-	buildArgs    []string
-	excludeFiles map[string]bool
-	excludeDirs  map[string]bool
-	includeFiles map[string]bool
-	monitorDirs  []string
-	workDir      string
-	cmd          string
-	args         []string
+	runName    string
+	proc       *exec.Cmd
+	restartSig chan bool
+	stopCtx    context.Context
+	cancle     context.CancelFunc
+	buildEnv   []string
+	buildArgs  []string
+	matcher    *filewatch.Matcher
+	workDir    string
+	cmd        string
+	args       []string
 }
 
 func NewRun() *Run {
 	ctx, cancle := context.WithCancel(context.Background())
 	return &Run{
-		cancle:       cancle,
-		stopCtx:      ctx,
-		restartSig:   make(chan bool),
-		onlyExts:     map[string]bool{},
-		excludeFiles: map[string]bool{},
-		excludeDirs:  map[string]bool{},
-		includeFiles: map[string]bool{},
+		cancle:     cancle,
+		stopCtx:    ctx,
+		restartSig: make(chan bool),
 	}
 }
 
@@ -99,33 +92,21 @@ func (s *Run) init() (err error) {
 	if err != nil {
 		return
 	}
-	for _, v := range cfg.GetStringSlice("build.include_exts") {
-		s.onlyExts[v] = true
-	}
 	curdir, _ := os.Getwd()
-	for _, v := range cfg.GetStringSlice("build.monitor_dirs") {
-		v = strings.Replace(v, "${DIR}", curdir, -1)
-		v, _ = filepath.Abs(v)
-		s.monitorDirs = append(s.monitorDirs, v)
-	}
-
-	for arr, cfgarr := range map[*map[string]bool][]string{
-		&s.excludeFiles: cfg.GetStringSlice("build.exclude_files"),
-		&s.excludeDirs:  cfg.GetStringSlice("build.exclude_dirs"),
-		&s.includeFiles: cfg.GetStringSlice("build.include_files"),
-	} {
-		for _, v := range cfgarr {
-			// ${DIR} presents current directory
-			v = strings.Replace(v, "${DIR}", curdir, -1)
-			if filepath.IsAbs(v) {
-				(*arr)[v] = true
-			} else {
-				for _, d := range s.monitorDirs {
-					(*arr)[filepath.Join(d, v)] = true
-				}
-			}
+	// ${DIR} presents current directory
+	var replaceDir = func(arr []string) []string {
+		for i, v := range arr {
+			arr[i] = strings.Replace(v, "${DIR}", curdir, -1)
 		}
+		return arr
 	}
+	s.matcher = filewatch.NewMatcher(
+		replaceDir(cfg.GetStringSlice("build.monitor_dirs")),
+		cfg.GetStringSlice("build.include_exts"),
+		replaceDir(cfg.GetStringSlice("build.include_files")),
+		replaceDir(cfg.GetStringSlice("build.exclude_files")),
+		replaceDir(cfg.GetStringSlice("build.exclude_dirs")),
+	)
 
 	s.workDir, _ = filepath.Abs(".")
 	s.buildEnv = os.Environ()
@@ -143,8 +124,6 @@ func (s *Run) init() (err error) {
 		s.runName = s.cmd
 	}
 
-	//fmt.Println(s.runName, s.monitorDirs, "\n", s.onlyExts, "\n", s.includeFiles, "\n", s.excludeFiles, "\n", s.excludeDirs)
-
 	gmchook.RegistShutdown(func() {
 		s.cancle()
 		s.kill()
@@ -203,54 +182,12 @@ func (s *Run) restartMonitor() {
 	}
 }
 func (s *Run) scan() {
-	list := map[string]int64{}
+	scanner := filewatch.NewScanner(s.matcher)
 	for {
 		select {
 		case <-s.stopCtx.Done():
 		case <-time.After(time.Second * 2):
-			restart := false
-			var names []string
-			var newList = map[string]bool{}
-			for _, d := range s.monitorDirs {
-				s.tree(d, &names)
-			}
-			for _, v := range names {
-				newList[v] = true
-			}
-
-			// deleted files found?
-			for k := range list {
-				if !newList[k] {
-					restart = true
-					delete(list, k)
-				}
-			}
-
-			// added and changed will be monitor here
-			if !restart {
-				for _, v := range names {
-					st, err := os.Stat(v)
-					if err != nil {
-						continue
-					}
-					t0 := st.ModTime().Unix()
-					if t, ok := list[v]; ok {
-						// found, compare modify time
-						if t0 != t {
-							//restart
-							//fmt.Printf("modify file: %s, %d -> %d\n", v, t, t0)
-							restart = true
-						}
-					} else {
-						// not found, restart
-						//fmt.Printf("new file: %s\n", v)
-						restart = true
-					}
-					list[v] = t0
-				}
-			}
-
-			if restart {
+			if scanner.Changed() {
 				select {
 				case s.restartSig <- true:
 				default:
@@ -264,68 +201,3 @@ func (s *Run) Stop() {
 	s.cancle()
 	return
 }
-
-func (s *Run) tree(folder string, names *[]string) (err error) {
-	name := filepath.Base(folder)
-	if strings.HasPrefix(name, ".") {
-		return
-	}
-	f, err := os.Open(folder)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	finfo, err := f.Stat()
-	if err != nil {
-		return
-	}
-	if !finfo.IsDir() {
-		return
-	}
-	files, err := filepath.Glob(folder + "/*")
-	if err != nil {
-		return
-	}
-	var file *os.File
-	for _, v := range files {
-		if file != nil {
-			file.Close()
-		}
-		file, err = os.Open(v)
-		if err != nil {
-			return
-		}
-		fileInfo, err := file.Stat()
-		if err != nil {
-			return err
-		}
-		n := filepath.Base(v)
-		if strings.HasPrefix(n, ".") {
-			continue
-		}
-		if fileInfo.IsDir() {
-			if s.excludeDirs[v] {
-				continue
-			}
-			err = s.tree(v, names)
-			if err != nil {
-				return err
-			}
-		} else {
-			if s.excludeFiles[v] {
-				continue
-			}
-			if !s.onlyExts[filepath.Ext(n)] && !s.includeFiles[v] {
-				continue
-			}
-			if strings.HasPrefix(filepath.Base(v), ".") {
-				continue
-			}
-			*names = append(*names, v)
-		}
-	}
-	if file != nil {
-		file.Close()
-	}
-	return
-}
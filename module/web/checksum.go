@@ -0,0 +1,57 @@
+package web
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/snail007/gmct/util/checksum"
+)
+
+var (
+	headerChecksumKey = "Checksum-Sha256"
+	fileChecksumCache = newChecksumCache()
+)
+
+type checksumCacheItem struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// checksumCache caches the SHA-256 of served files, an item is invalid when
+// the size or the modification time of the file changed.
+type checksumCache struct {
+	data map[string]*checksumCacheItem
+	l    sync.Mutex
+}
+
+func newChecksumCache() *checksumCache {
+	return &checksumCache{data: map[string]*checksumCacheItem{}}
+}
+
+// Get returns the SHA-256 of file, it is computed only if not cached or file changed.
+func (s *checksumCache) Get(file string) (sum string, err error) {
+	st, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	s.l.Lock()
+	item := s.data[file]
+	s.l.Unlock()
+	if item != nil && item.size == st.Size() && item.modTime.Equal(st.ModTime()) {
+		return item.sum, nil
+	}
+	sum, err = checksum.SHA256sum(file)
+	if err != nil {
+		return
+	}
+	s.l.Lock()
+	s.data[file] = &checksumCacheItem{
+		size:    st.Size(),
+		modTime: st.ModTime(),
+		sum:     sum,
+	}
+	s.l.Unlock()
+	return
+}
@@ -2,17 +2,12 @@ package web
 
 import (
 	"bytes"
-	"encoding/base64"
 	"fmt"
 	gcast "github.com/snail007/gmc/util/cast"
-	grand "github.com/snail007/gmc/util/rand"
 	"github.com/snail007/gmct/util"
-	"io"
 	"net"
-	"net/http"
 	URL "net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -21,7 +16,6 @@ import (
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gobwas/glob"
-	"github.com/schollz/progressbar/v3"
 	glog "github.com/snail007/gmc/module/log"
 	gfile "github.com/snail007/gmc/util/file"
 	"github.com/snail007/gmc/util/gpool"
@@ -48,18 +42,24 @@ func init() {
 }
 
 type DownloadArgs struct {
-	Port         []string
-	Net          []string
-	Name         string
-	File         string
-	MaxDeepLevel int
-	Host         []string
-	Auth         string
-	ServerID     string
-	DownloadAll  bool
-	Timeout      int
-	DownloadDir  string
-	cfg          *viper.Viper
+	Port            []string
+	Net             []string
+	Name            string
+	File            string
+	MaxDeepLevel    int
+	Host            []string
+	Auth            string
+	ServerID        string
+	DownloadAll     bool
+	Timeout         int
+	DownloadDir     string
+	Threads         int
+	NoVerify        bool
+	Retry           int
+	Yes             bool
+	OverwritePolicy OverwritePolicy
+	JSON            bool
+	cfg             *viper.Viper
 }
 
 type serverFileItem struct {
@@ -79,6 +79,10 @@ func (s *Tool) initDownload(args *DownloadArgs) *DownloadArgs {
 		glog.Panic("download file name required, use option: -f xxx")
 		return nil
 	}
+	return s.loadDownloadConfig(args)
+}
+
+func (s *Tool) loadDownloadConfig(args *DownloadArgs) *DownloadArgs {
 	f := filepath.Join(gfile.HomeDir(), defaultConfigName)
 	if gfile.Exists(f) {
 		cfg := viper.New()
@@ -105,60 +109,115 @@ host=[]
 # example: auth="foo_user:foo_password", username and password seperated by a colon.
 # if --auth option is specified, the auth here will ignored.
 auth=""
+
+# default upload url path used by gmct upload, it is the value of option -u of gmct web.
+# if --path option is specified, the upload here will ignored.
+upload=""
 `, false)
 	}
 	return args
 }
-func (s *Tool) download(args *DownloadArgs) {
+func (s *Tool) download(args *DownloadArgs) (result *downloadResult, err error) {
+	result = &downloadResult{}
 	basename := strings.TrimPrefix(args.Name, "/")
 	if basename != "" {
-		if !s.confirmOverwrite(basename) {
-			return
+		ok, e := s.confirmOverwrite(basename, args)
+		if !ok {
+			if e == nil {
+				// the server is not connected yet, so the url of skipped file is unknown.
+				result.skip("", gfile.Abs(basename))
+			}
+			return result, e
 		}
 		if strings.Contains(basename, "/") {
 			dir, _ := filepath.Abs(filepath.Dir(basename))
-			err := os.MkdirAll(dir, 0755)
+			err = os.MkdirAll(dir, 0755)
 			if err != nil {
-				glog.Fatalf("create directory [%s] fail, error: %s", dir, err)
+				return result, fmt.Errorf("create directory [%s] fail, error: %s", dir, err)
 			}
 		}
 	}
-
-	foundFiles := s.getFoundFiles(s.getServerURL(args), args)
+	server, err := s.getServerURL(args)
+	if err != nil {
+		return
+	}
+	result.Server = server.url.String()
+	foundFiles, err := s.getFoundFiles(server, args)
+	if err != nil {
+		return
+	}
 	if len(foundFiles) == 1 {
 		foundFile := foundFiles[0]
 		if basename == "" {
 			basename = filepath.Base(foundFile.url.String())
-			if !s.confirmOverwrite(basename) {
-				return
+			ok, e := s.confirmOverwrite(basename, args)
+			if !ok {
+				if e == nil {
+					result.skip(foundFile.url.String(), gfile.Abs(basename))
+				}
+				return result, e
 			}
 		}
-		s.downloadFile(1, 1, basename, foundFile, "./")
+		s.downloadFileToResult(result, 1, 1, basename, foundFile, "./", args)
 	} else {
 		total := len(foundFiles)
 		for i, foundFile := range foundFiles {
 			basename = filepath.Base(foundFile.url.Path)
 			dir, _ := filepath.Abs(filepath.Join(args.DownloadDir, strings.TrimPrefix(filepath.Dir(foundFile.url.Path), "/")))
-			err := os.MkdirAll(dir, 0755)
+			err = os.MkdirAll(dir, 0755)
 			if err != nil {
-				glog.Fatalf("create directory [%s] fail, error: %s", dir, err)
+				return result, fmt.Errorf("create directory [%s] fail, error: %s", dir, err)
+			}
+			// keep overwriting silently as before when no policy specified
+			if args.OverwritePolicy != OverwritePrompt {
+				ok, e := s.confirmOverwrite(filepath.Join(dir, basename), args)
+				if e != nil {
+					result.add(foundFile.url.String(), filepath.Join(dir, basename), 0, e)
+					continue
+				}
+				if !ok {
+					result.skip(foundFile.url.String(), filepath.Join(dir, basename))
+					continue
+				}
 			}
-			s.downloadFile(i+1, total, basename, foundFile, dir)
+			s.downloadFileToResult(result, i+1, total, basename, foundFile, dir, args)
 		}
 	}
+	if n := result.failedCount(); n > 0 {
+		err = newDownloadError(exitCodeTransferFailed, "%d of %d files failed to download", n, len(result.Files))
+	}
+	return
+}
+
+func (s *Tool) downloadFileToResult(result *downloadResult, i, total int, basename string, foundFile *serverFileItem, dir string, args *DownloadArgs) {
+	dstFile, _ := filepath.Abs(filepath.Join(dir, basename))
+	err := s.downloadFile(i, total, basename, foundFile, dir, args)
+	if err != nil {
+		glog.Warnf("download error: %s", err)
+	}
+	size, _ := gfile.FileSize(dstFile)
+	result.add(foundFile.url.String(), dstFile, size, err)
 }
 
 // 1
-func (s *Tool) getServerURL(args *DownloadArgs) *serverItem {
-	gmctWebServerList := s.getWebServerList(args)
+func (s *Tool) getServerURL(args *DownloadArgs) (*serverItem, error) {
+	scanURLArr, err := s.getScanURLs(args)
+	if err != nil {
+		return nil, err
+	}
+	gmctWebServerList := s.getWebServerList(scanURLArr, args)
 	if len(gmctWebServerList) == 0 {
 		n := []string{}
-		for _, v := range s.getSubnetArr(args) {
+		subnetArr, _ := s.getSubnetArr(args)
+		for _, v := range subnetArr {
 			n = append(n, v+"0")
 		}
-		glog.Fatalf("none gmct http server found, scan: %d, net: %v", len(s.getScanURLs(args)), n)
+		return nil, newDownloadError(exitCodeNoServer, "none gmct http server found, scan: %d, net: %v", len(scanURLArr), n)
 	}
 	serverURL := gmctWebServerList[0]
+	if len(gmctWebServerList) > 1 && !args.isInteractive() {
+		return nil, newDownloadError(exitCodeMultipleServers, "%d gmct http servers found, specify one by option --id or --host", len(gmctWebServerList))
+	}
 	if len(gmctWebServerList) > 1 {
 		selectIdx := []string{}
 		for idx := range gmctWebServerList {
@@ -193,16 +252,15 @@ func (s *Tool) getServerURL(args *DownloadArgs) *serverItem {
 		}{}
 		e := survey.Ask(qs, &answers)
 		if e != nil {
-			glog.Fatal(e.Error())
+			return nil, e
 		}
 		serverURL = gmctWebServerList[answers.Index]
 	}
-	return serverURL
+	return serverURL, nil
 }
 
 // 2
-func (s *Tool) getWebServerList(args *DownloadArgs) []*serverItem {
-	scanURLArr := s.getScanURLs(args)
+func (s *Tool) getWebServerList(scanURLArr []string, args *DownloadArgs) []*serverItem {
 	length := len(scanURLArr)
 	pool := gpool.New(length)
 	g := sync.WaitGroup{}
@@ -242,7 +300,7 @@ func (s *Tool) getWebServerList(args *DownloadArgs) []*serverItem {
 }
 
 // 3
-func (s *Tool) getScanURLs(args *DownloadArgs) []string {
+func (s *Tool) getScanURLs(args *DownloadArgs) ([]string, error) {
 	serverURLs := []string{}
 	var getHostURL = func(host string) string {
 		u, _ := URL.Parse(fmt.Sprintf("http://%s/", host))
@@ -257,7 +315,7 @@ func (s *Tool) getScanURLs(args *DownloadArgs) []string {
 		serverURLs = append(serverURLs, getHostURL(host))
 	}
 	if len(serverURLs) > 0 {
-		return serverURLs
+		return serverURLs, nil
 	}
 
 	//  from config file
@@ -270,12 +328,15 @@ func (s *Tool) getScanURLs(args *DownloadArgs) []string {
 		}
 	}
 	if len(serverURLs) > 0 {
-		return serverURLs
+		return serverURLs, nil
 	}
 
 	//  from auto scan
 	scanURLArr := []string{}
-	subnetArr := s.getSubnetArr(args)
+	subnetArr, err := s.getSubnetArr(args)
+	if err != nil {
+		return nil, err
+	}
 	portList := gset.New()
 	portList.MergeStringSlice(args.Port)
 	portList.Add(DefaultPort)
@@ -290,50 +351,57 @@ func (s *Tool) getScanURLs(args *DownloadArgs) []string {
 			}
 		}
 	}
-	return scanURLArr
+	return scanURLArr, nil
 }
 
 // 4
-func (s *Tool) getSubnetArr(args *DownloadArgs) []string {
-	var checkNet = func(ip string) string {
+func (s *Tool) getSubnetArr(args *DownloadArgs) ([]string, error) {
+	subnetList := gset.New()
+	var addNet = func(ip string) error {
 		n := net.ParseIP(ip)
 		if n == nil || n.To4() == nil {
-			glog.Fatalf("parse network error, %s", ip)
+			return newDownloadError(exitCodeInvalidArgs, "parse network error, %s", ip)
 		}
-		return strings.Join(strings.Split(ip, ".")[:3], ".") + "."
+		subnetList.Add(strings.Join(strings.Split(ip, ".")[:3], ".") + ".")
+		return nil
 	}
-	subnetList := gset.New()
 	for _, v := range args.Net {
-		subnetList.Add(checkNet(v))
+		if err := addNet(v); err != nil {
+			return nil, err
+		}
 	}
 	if subnetList.Len() == 0 {
 		net := s.getDownloadConfig("net", args)
 		if net != nil {
 			for _, v := range net.([]interface{}) {
-				subnetList.Add(checkNet(v.(string)))
+				if err := addNet(fmt.Sprintf("%v", v)); err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
 	if subnetList.Len() == 0 {
 		for _, v := range util.GetLocalIP() {
-			subnetList.Add(checkNet(v))
+			if err := addNet(v); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return subnetList.ToStringSlice()
+	return subnetList.ToStringSlice(), nil
 }
 
 // 1
-func (s *Tool) getFoundFiles(serverItem *serverItem, args *DownloadArgs) (foundFiles []*serverFileItem) {
+func (s *Tool) getFoundFiles(serverItem *serverItem, args *DownloadArgs) (foundFiles []*serverFileItem, err error) {
 	var files []*serverFileItem
 	s.listFiles(serverItem, "", args, &files)
 	if len(files) == 0 {
-		glog.Fatalf("no files found on the specify server, %s", serverItem.url.Host)
+		return nil, newDownloadError(exitCodeNoMatch, "no files found on the specify server, %s", serverItem.url.Host)
 	}
 	// filter files
 	toMatch := args.File
 	g, err := glob.Compile(toMatch)
 	if err != nil {
-		glog.Fatalf("parse file name [%s] error: %s", toMatch, err)
+		return nil, fmt.Errorf("parse file name [%s] error: %s", toMatch, err)
 	}
 	for _, f := range files {
 		basename := filepath.Base(f.url.Path)
@@ -346,13 +414,16 @@ func (s *Tool) getFoundFiles(serverItem *serverItem, args *DownloadArgs) (foundF
 		}
 	}
 	if len(foundFiles) == 0 {
-		glog.Fatalf("no matched file found on the specify server, %s, %s", serverItem.url.Host, toMatch)
+		return nil, newDownloadError(exitCodeNoMatch, "no matched file found on the specify server, %s, %s", serverItem.url.Host, toMatch)
 	}
 
 	if len(foundFiles) > 1 {
-		if args.DownloadAll {
+		if args.DownloadAll || args.Yes {
 			//download all
-			return foundFiles
+			return foundFiles, nil
+		}
+		if !args.isInteractive() {
+			return nil, fmt.Errorf("multiple matched files(%d) found, use option --all or --yes to download all files", len(foundFiles))
 		}
 		foundFiles = append([]*serverFileItem{nil}, foundFiles...)
 		selectIdx := []string{}
@@ -385,16 +456,16 @@ func (s *Tool) getFoundFiles(serverItem *serverItem, args *DownloadArgs) (foundF
 		}{}
 		e := survey.Ask(qs, &answers)
 		if e != nil {
-			glog.Fatal(e.Error())
+			return nil, e
 		}
 		if answers.Index == 0 {
 			//download all
-			return foundFiles[1:]
+			return foundFiles[1:], nil
 		}
 		//select a file
-		return []*serverFileItem{foundFiles[answers.Index]}
+		return []*serverFileItem{foundFiles[answers.Index]}, nil
 	}
-	return foundFiles
+	return foundFiles, nil
 }
 
 // 2
@@ -486,112 +557,59 @@ func (s *Tool) getDownloadHTTPClient(auth []string, scanURL *URL.URL, args *Down
 	}
 	return user, pass, client
 }
-func (s *Tool) confirmOverwrite(basename string) bool {
-	if gfile.Exists(basename) {
-		var qs = []*survey.Question{{
-			Name: "confirm",
-			Prompt: &survey.Confirm{
-				Message: "file [" + basename + "] already exists, overwrite it?",
-				Default: false,
-			},
-			Validate: survey.Required,
+
+// confirmOverwrite returns true if the file not exists or it can be overwritten,
+// an error returned if the file exists and it is not allowed to skip.
+func (s *Tool) confirmOverwrite(basename string, args *DownloadArgs) (bool, error) {
+	if !gfile.Exists(basename) {
+		return true, nil
+	}
+	switch args.OverwritePolicy {
+	case OverwriteYes:
+		return true, nil
+	case OverwriteSkip:
+		fmt.Fprintln(args.stdout(), "skip existing file: "+basename)
+		return false, nil
+	case OverwriteNo:
+		return false, fmt.Errorf("file [%s] already exists", basename)
+	}
+	if !args.isInteractive() {
+		return false, fmt.Errorf("file [%s] already exists, use option --yes to overwrite it or --skip-existing to skip it", basename)
+	}
+	var qs = []*survey.Question{{
+		Name: "confirm",
+		Prompt: &survey.Confirm{
+			Message: "file [" + basename + "] already exists, overwrite it?",
+			Default: false,
 		},
-		}
-		answers := struct {
-			Confirm bool
-		}{}
-		e := survey.Ask(qs, &answers)
-		if e != nil {
-			glog.Fatal(e.Error())
-		}
-		return answers.Confirm
+		Validate: survey.Required,
+	},
 	}
-	return true
-}
-func (s *Tool) downloadFile(i, total int, basename string, foundFile *serverFileItem, dir string) {
-	downloadURL := foundFile.url.String()
-	fmt.Println("downloading: " + downloadURL)
-	_, err := exec.LookPath("axel")
-	if err == nil {
-		sid := fmt.Sprintf("/tmp/tmp_%d", grand.New().Int31()) + ".sh"
-		defer os.Remove(sid)
-		finalCmd := `#!/bin/bash
-cd ` + dir + `
-axel $AXEL_ARGS ` + s.basicAuthHeader(foundFile) + `"` + downloadURL + `"`
-		gfile.WriteString(sid, finalCmd, false)
-		fmt.Println("Command axel found and will be used to download, " +
-			"set AXEL_ARGS environment variable to pass the additional args to axel")
-		cmd := exec.Command("bash", sid)
-		cmd.Env = append(cmd.Env, "AXEL_ARGS="+os.Getenv("AXEL_ARGS"))
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err := cmd.Run()
-		if err != nil {
-			fmt.Println("exec axel error: " + err.Error())
-		}
-		return
-	}
-	req, _ := http.NewRequest("GET", downloadURL, nil)
-	if req != nil && foundFile.server.auth != nil {
-		req.SetBasicAuth(foundFile.server.auth[0], foundFile.server.auth[1])
-	}
-	resp, e := http.DefaultClient.Do(req)
-	if e != nil {
-		glog.Warnf("download error: %s", e)
-		return
-	}
-	defer resp.Body.Close()
-	tmpfile := basename + ".tmp"
-	f, _ := os.OpenFile(tmpfile, os.O_CREATE|os.O_WRONLY, 0644)
-	defer f.Close()
-	bar := progressbar.NewOptions64(
-		resp.ContentLength,
-		progressbar.OptionSetDescription(fmt.Sprintf("(%d/%d)", i, total)),
-		progressbar.OptionSetWriter(os.Stderr),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetWidth(10),
-		progressbar.OptionThrottle(65*time.Millisecond),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Fprint(os.Stderr, "\n")
-		}),
-		progressbar.OptionSpinnerType(14),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "=",
-			SaucerHead:    ">",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-	)
-	bar.RenderBlank()
-	_, e = io.Copy(io.MultiWriter(f, bar), resp.Body)
+	answers := struct {
+		Confirm bool
+	}{}
+	e := survey.Ask(qs, &answers)
 	if e != nil {
-		glog.Warnf("write download file error: %s, file: %s", e, gfile.Abs(tmpfile))
-		return
+		return false, e
 	}
-	if gfile.Exists(basename) {
-		e = os.Remove(basename)
-		if e != nil {
-			glog.Warnf("remove old file error: %s, file: %s", e, gfile.Abs(basename))
-			return
+	return answers.Confirm, nil
+}
+func (s *Tool) downloadFile(i, total int, basename string, foundFile *serverFileItem, dir string, args *DownloadArgs) (err error) {
+	downloadURL := foundFile.url.String()
+	fmt.Fprintln(args.stdout(), "downloading: "+downloadURL)
+	dstFile := filepath.Join(dir, basename)
+	d := newRangeDownloader(downloadURL, foundFile.server.auth, dstFile, args.Threads, !args.NoVerify)
+	for try := 0; ; try++ {
+		err = d.Download(fmt.Sprintf("(%d/%d)", i, total))
+		if _, ok := err.(*checksumError); ok && try < args.Retry {
+			glog.Warnf("%s, retrying...", err)
+			continue
 		}
+		break
 	}
-	dstfile := filepath.Join(dir, basename)
-	e = os.Rename(tmpfile, dstfile)
-	if e != nil {
-		os.Remove(tmpfile)
-		glog.Warnf("rename file error: %s, [%s] to [%s]", e, tmpfile, dstfile)
+	if err != nil {
 		return
 	}
 	glog.Info("download SUCCESS")
-}
-
-func (s *Tool) basicAuthHeader(foundFile *serverFileItem) string {
-	if foundFile.server.auth == nil {
-		return ""
-	}
-	auth := foundFile.server.auth[0] + ":" + foundFile.server.auth[1]
-	return fmt.Sprintf(` -H "Authorization: Basic %s" `, base64.StdEncoding.EncodeToString([]byte(auth)))
+	return
 }
@@ -0,0 +1,421 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	gfile "github.com/snail007/gmc/util/file"
+	"github.com/snail007/gmct/util/checksum"
+)
+
+const (
+	partSuffix       = ".part"
+	partMetaSuffix   = ".part.meta"
+	minSegmentSize   = 1 << 20
+	segmentMaxRetry  = 3
+	metaSaveInterval = time.Second
+)
+
+// remoteFileInfo is the result of a HEAD request to the file url.
+type remoteFileInfo struct {
+	size         int64
+	lastModified string
+	acceptRanges bool
+	checksum     string
+}
+
+// downloadSegment is a byte range of the remote file, end is inclusive,
+// done is the count of bytes already written to the .part file.
+type downloadSegment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (s *downloadSegment) length() int64 {
+	return s.End - s.Start + 1
+}
+
+// downloadMeta is saved to the .part.meta file beside the .part file,
+// it records the progress of each segment for resuming.
+type downloadMeta struct {
+	URL          string             `json:"url"`
+	Size         int64              `json:"size"`
+	LastModified string             `json:"last_modified"`
+	Segments     []*downloadSegment `json:"segments"`
+}
+
+func (s *downloadMeta) downloaded() (n int64) {
+	for _, seg := range s.Segments {
+		n += seg.Done
+	}
+	return
+}
+
+// rangeDownloader downloads a file in parallel segments using HTTP Range requests,
+// data is written to <dstFile>.part, and moved to dstFile after the size is verified.
+type rangeDownloader struct {
+	url      string
+	auth     []string
+	dstFile  string
+	threads  int
+	verify   bool
+	client   *http.Client
+	meta     *downloadMeta
+	metaLock sync.Mutex
+}
+
+func newRangeDownloader(url string, auth []string, dstFile string, threads int, verify bool) *rangeDownloader {
+	if threads <= 0 {
+		threads = 1
+	}
+	return &rangeDownloader{
+		url:     url,
+		auth:    auth,
+		dstFile: dstFile,
+		threads: threads,
+		verify:  verify,
+		client:  &http.Client{Transport: http.DefaultTransport},
+	}
+}
+
+func (s *rangeDownloader) partFile() string {
+	return s.dstFile + partSuffix
+}
+
+func (s *rangeDownloader) metaFile() string {
+	return s.dstFile + partMetaSuffix
+}
+
+func (s *rangeDownloader) newRequest(method string) (*http.Request, error) {
+	req, err := http.NewRequest(method, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.auth) == 2 {
+		req.SetBasicAuth(s.auth[0], s.auth[1])
+	}
+	return req, nil
+}
+
+func (s *rangeDownloader) stat() (info *remoteFileInfo, err error) {
+	req, err := s.newRequest(http.MethodHead)
+	if err != nil {
+		return
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return &remoteFileInfo{
+		size:         resp.ContentLength,
+		lastModified: resp.Header.Get("Last-Modified"),
+		acceptRanges: strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"),
+		checksum:     resp.Header.Get(headerChecksumKey),
+	}, nil
+}
+
+// Download downloads the file, the desc is showed as the progress bar prefix.
+func (s *rangeDownloader) Download(desc string) (err error) {
+	info, err := s.stat()
+	if err != nil {
+		return
+	}
+	if !info.acceptRanges || info.size <= 0 {
+		return s.downloadSingle(desc, info)
+	}
+	s.meta = s.loadMeta(info)
+	if s.meta == nil {
+		s.meta = s.newMeta(info)
+		if err = s.createPartFile(info.size); err != nil {
+			return
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "resume from %s, %d/%d bytes downloaded\n", s.partFile(), s.meta.downloaded(), info.size)
+	}
+	if err = s.saveMeta(); err != nil {
+		return
+	}
+	f, err := os.OpenFile(s.partFile(), os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	bar := newProgressBar(info.size, desc)
+	bar.Add64(s.meta.downloaded())
+
+	stopSave := make(chan bool)
+	saveDone := make(chan bool)
+	go func() {
+		defer close(saveDone)
+		for {
+			select {
+			case <-stopSave:
+				return
+			case <-time.After(metaSaveInterval):
+				s.saveMeta()
+			}
+		}
+	}()
+	errs := make(chan error, len(s.meta.Segments))
+	g := sync.WaitGroup{}
+	for _, seg := range s.meta.Segments {
+		if seg.Done >= seg.length() {
+			continue
+		}
+		g.Add(1)
+		go func(seg *downloadSegment) {
+			defer g.Done()
+			var e error
+			for i := 0; i < segmentMaxRetry; i++ {
+				if e = s.downloadSegment(f, seg, bar); e == nil {
+					return
+				}
+				time.Sleep(time.Second * time.Duration(i+1))
+			}
+			errs <- e
+		}(seg)
+	}
+	g.Wait()
+	close(stopSave)
+	// wait for the saving in progress, it may race with the saving below and the removing in finish.
+	<-saveDone
+	close(errs)
+	if err = s.saveMeta(); err != nil {
+		return
+	}
+	if e, ok := <-errs; ok {
+		return fmt.Errorf("%s, run the same command again to resume", e)
+	}
+	f.Close()
+	if n := s.meta.downloaded(); n != info.size {
+		return fmt.Errorf("size verification fail, expected %d bytes, got %d bytes", info.size, n)
+	}
+	return s.finish(info)
+}
+
+func (s *rangeDownloader) downloadSegment(f *os.File, seg *downloadSegment, bar *progressbar.ProgressBar) (err error) {
+	s.metaLock.Lock()
+	offset := seg.Start + seg.Done
+	s.metaLock.Unlock()
+	req, err := s.newRequest(http.MethodGet)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, seg.End))
+	if s.meta.LastModified != "" {
+		req.Header.Set("If-Range", s.meta.LastModified)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range request fail, response status: %s", resp.Status)
+	}
+	buf := make([]byte, 32*1024)
+	for offset <= seg.End {
+		n, e := resp.Body.Read(buf)
+		if n > 0 {
+			if int64(n) > seg.End-offset+1 {
+				n = int(seg.End - offset + 1)
+			}
+			if _, err = f.WriteAt(buf[:n], offset); err != nil {
+				return
+			}
+			offset += int64(n)
+			s.metaLock.Lock()
+			seg.Done += int64(n)
+			s.metaLock.Unlock()
+			bar.Add(n)
+		}
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return e
+		}
+	}
+	if offset <= seg.End {
+		return fmt.Errorf("segment %d-%d is incomplete", seg.Start, seg.End)
+	}
+	return
+}
+
+// downloadSingle is used when the server does not support Range requests.
+func (s *rangeDownloader) downloadSingle(desc string, info *remoteFileInfo) (err error) {
+	req, err := s.newRequest(http.MethodGet)
+	if err != nil {
+		return
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	f, err := os.OpenFile(s.partFile(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	bar := newProgressBar(resp.ContentLength, desc)
+	_, err = io.Copy(io.MultiWriter(f, bar), resp.Body)
+	if err != nil {
+		return fmt.Errorf("write download file error: %s, file: %s", err, gfile.Abs(s.partFile()))
+	}
+	f.Close()
+	if info.size < 0 {
+		info.size = resp.ContentLength
+	}
+	return s.finish(info)
+}
+
+// finish verifies the size and checksum of .part file and renames it to the destination file.
+func (s *rangeDownloader) finish(info *remoteFileInfo) (err error) {
+	if size := info.size; size >= 0 {
+		st, e := os.Stat(s.partFile())
+		if e != nil {
+			return e
+		}
+		if st.Size() != size {
+			os.Remove(s.partFile())
+			os.Remove(s.metaFile())
+			return fmt.Errorf("size verification fail, expected %d bytes, got %d bytes", size, st.Size())
+		}
+	}
+	if s.verify && info.checksum != "" {
+		sum, e := checksum.SHA256sum(s.partFile())
+		if e != nil {
+			return e
+		}
+		if !strings.EqualFold(sum, info.checksum) {
+			os.Remove(s.partFile())
+			os.Remove(s.metaFile())
+			return &checksumError{expected: info.checksum, actual: sum}
+		}
+	}
+	if gfile.Exists(s.dstFile) {
+		if err = os.Remove(s.dstFile); err != nil {
+			return fmt.Errorf("remove old file error: %s, file: %s", err, gfile.Abs(s.dstFile))
+		}
+	}
+	if err = os.Rename(s.partFile(), s.dstFile); err != nil {
+		return fmt.Errorf("rename file error: %s, [%s] to [%s]", err, s.partFile(), s.dstFile)
+	}
+	os.Remove(s.metaFile())
+	return
+}
+
+func (s *rangeDownloader) newMeta(info *remoteFileInfo) *downloadMeta {
+	meta := &downloadMeta{
+		URL:          s.url,
+		Size:         info.size,
+		LastModified: info.lastModified,
+	}
+	threads := int64(s.threads)
+	if max := info.size / minSegmentSize; threads > max {
+		threads = max
+	}
+	if threads < 1 {
+		threads = 1
+	}
+	segSize := info.size / threads
+	for i := int64(0); i < threads; i++ {
+		seg := &downloadSegment{Start: i * segSize, End: (i+1)*segSize - 1}
+		if i == threads-1 {
+			seg.End = info.size - 1
+		}
+		meta.Segments = append(meta.Segments, seg)
+	}
+	return meta
+}
+
+// loadMeta loads the saved progress, nil returned if it is missing or the remote file changed.
+func (s *rangeDownloader) loadMeta(info *remoteFileInfo) *downloadMeta {
+	st, err := os.Stat(s.partFile())
+	if err != nil || st.Size() != info.size {
+		return nil
+	}
+	b, err := os.ReadFile(s.metaFile())
+	if err != nil {
+		return nil
+	}
+	meta := &downloadMeta{}
+	if err = json.Unmarshal(b, meta); err != nil {
+		return nil
+	}
+	if meta.URL != s.url || meta.Size != info.size || meta.LastModified != info.lastModified || len(meta.Segments) == 0 {
+		return nil
+	}
+	return meta
+}
+
+func (s *rangeDownloader) saveMeta() error {
+	s.metaLock.Lock()
+	b, err := json.Marshal(s.meta)
+	s.metaLock.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.metaFile(), b, 0644)
+}
+
+func (s *rangeDownloader) createPartFile(size int64) error {
+	f, err := os.OpenFile(s.partFile(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Truncate(size)
+}
+
+type checksumError struct {
+	expected string
+	actual   string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("sha256 checksum mismatch, expected %s, got %s", e.expected, e.actual)
+}
+
+func newProgressBar(total int64, desc string) *progressbar.ProgressBar {
+	bar := progressbar.NewOptions64(
+		total,
+		progressbar.OptionSetDescription(desc),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "=",
+			SaucerHead:    ">",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+	bar.RenderBlank()
+	return bar
+}
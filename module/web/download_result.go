@@ -0,0 +1,133 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	glog "github.com/snail007/gmc/module/log"
+	gfile "github.com/snail007/gmc/util/file"
+	"golang.org/x/term"
+)
+
+// exit codes of download, sync and upload commands, 1 is used for other errors.
+const (
+	exitCodeNoServer       = 2
+	exitCodeNoMatch        = 3
+	exitCodeTransferFailed = 4
+	// exitCodeMultipleServers is used when more than one server found in non-interactive mode.
+	exitCodeMultipleServers = 5
+	// exitCodeInvalidArgs is used when the options or config are invalid, such as a bad --net value.
+	exitCodeInvalidArgs = 6
+)
+
+type OverwritePolicy int
+
+const (
+	// OverwritePrompt asks user whether to overwrite the existing file,
+	// it equals OverwriteNo in non-interactive mode.
+	OverwritePrompt OverwritePolicy = iota
+	OverwriteYes
+	OverwriteNo
+	OverwriteSkip
+)
+
+type downloadStatus string
+
+const (
+	downloadStatusDownloaded downloadStatus = "downloaded"
+	downloadStatusSkipped    downloadStatus = "skipped"
+	downloadStatusFailed     downloadStatus = "failed"
+)
+
+type downloadError struct {
+	code int
+	err  error
+}
+
+func (e *downloadError) Error() string {
+	return e.err.Error()
+}
+
+func newDownloadError(code int, format string, v ...interface{}) error {
+	return &downloadError{code: code, err: fmt.Errorf(format, v...)}
+}
+
+type downloadedFile struct {
+	URL    string         `json:"url"`
+	Path   string         `json:"path"`
+	Size   int64          `json:"size"`
+	Status downloadStatus `json:"status"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type downloadResult struct {
+	Server string            `json:"server"`
+	Files  []*downloadedFile `json:"files"`
+	Error  string            `json:"error,omitempty"`
+}
+
+func (s *downloadResult) add(url, path string, size int64, err error) {
+	f := &downloadedFile{URL: url, Path: path, Size: size, Status: downloadStatusDownloaded}
+	if err != nil {
+		f.Status = downloadStatusFailed
+		f.Error = err.Error()
+	}
+	s.Files = append(s.Files, f)
+}
+
+func (s *downloadResult) skip(url, path string) {
+	size, _ := gfile.FileSize(path)
+	s.Files = append(s.Files, &downloadedFile{URL: url, Path: path, Size: size, Status: downloadStatusSkipped})
+}
+
+func (s *downloadResult) failedCount() (n int) {
+	for _, f := range s.Files {
+		if f.Status == downloadStatusFailed {
+			n++
+		}
+	}
+	return
+}
+
+// isInteractive returns false if any non-interactive option is set or stdin is not a terminal,
+// then the survey prompts are never used.
+func (s *DownloadArgs) isInteractive() bool {
+	return !s.Yes && !s.JSON && s.OverwritePolicy == OverwritePrompt && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// stdout returns the writer for informational messages, it is stderr in json mode,
+// so the stdout only contains the json output.
+func (s *DownloadArgs) stdout() io.Writer {
+	if s.JSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// exitDownload prints the result in json mode, and exits with the code of err.
+func exitDownload(args *DownloadArgs, result *downloadResult, err error) {
+	if args != nil && args.JSON {
+		if result == nil {
+			result = &downloadResult{}
+		}
+		if result.Files == nil {
+			result.Files = []*downloadedFile{}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		b, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(b))
+	} else if err != nil {
+		glog.Error(err)
+	}
+	if err == nil {
+		os.Exit(0)
+	}
+	if e, ok := err.(*downloadError); ok {
+		os.Exit(e.code)
+	}
+	os.Exit(1)
+}
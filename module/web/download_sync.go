@@ -0,0 +1,203 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	URL "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	glog "github.com/snail007/gmc/module/log"
+	gfile "github.com/snail007/gmc/util/file"
+	"github.com/snail007/gmct/util/checksum"
+)
+
+type SyncArgs struct {
+	*DownloadArgs
+	RemotePath string
+	LocalDir   string
+	Delete     bool
+	DryRun     bool
+	Hash       bool
+}
+
+// remoteEntry is a file in the remote tree, path is relative to the remote sync path.
+type remoteEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+type syncAction string
+
+const (
+	syncActionAdd    syncAction = "+"
+	syncActionUpdate syncAction = "~"
+	syncActionDelete syncAction = "-"
+)
+
+type syncPlanItem struct {
+	action syncAction
+	path   string
+	remote *remoteEntry
+}
+
+func (s *Tool) sync(args *SyncArgs) (err error) {
+	server, err := s.getServerURL(args.DownloadArgs)
+	if err != nil {
+		return
+	}
+	remotePath := "/" + strings.Trim(args.RemotePath, "/")
+	if remotePath != "/" {
+		remotePath += "/"
+	}
+	var remoteFiles []*remoteEntry
+	err = s.listRemoteTree(server, remotePath, "", args.DownloadArgs, &remoteFiles)
+	if err != nil {
+		return
+	}
+	plan, err := s.syncPlan(server, remotePath, remoteFiles, args)
+	if err != nil {
+		return
+	}
+	fmt.Printf("sync plan: %s -> %s\n", server.url.String()+strings.TrimPrefix(remotePath, "/"), gfile.Abs(args.LocalDir))
+	downloadCnt, deleteCnt := 0, 0
+	for _, item := range plan {
+		if item.action == syncActionDelete {
+			deleteCnt++
+			fmt.Printf("  %s %s\n", item.action, item.path)
+		} else {
+			downloadCnt++
+			fmt.Printf("  %s %s (%d bytes)\n", item.action, item.path, item.remote.size)
+		}
+	}
+	fmt.Printf("%d to download, %d to delete\n", downloadCnt, deleteCnt)
+	if args.DryRun || len(plan) == 0 {
+		return
+	}
+	var failed []string
+	i := 0
+	for _, item := range plan {
+		localFile := filepath.Join(args.LocalDir, filepath.FromSlash(item.path))
+		if item.action == syncActionDelete {
+			if e := os.Remove(localFile); e != nil {
+				glog.Warnf("delete file error: %s", e)
+				failed = append(failed, item.path)
+			}
+			continue
+		}
+		i++
+		dir := filepath.Dir(localFile)
+		if e := os.MkdirAll(dir, 0755); e != nil {
+			glog.Warnf("create directory [%s] fail, error: %s", dir, e)
+			failed = append(failed, item.path)
+			continue
+		}
+		fileItem := &serverFileItem{
+			url:    server.url.ResolveReference(&URL.URL{Path: remotePath + item.path}),
+			server: server,
+		}
+		if e := s.downloadFile(i, downloadCnt, filepath.Base(localFile), fileItem, dir, args.DownloadArgs); e != nil {
+			glog.Warnf("download error: %s", e)
+			failed = append(failed, item.path)
+			continue
+		}
+		// keep the modification time same as remote, then next sync can compare it.
+		os.Chtimes(localFile, time.Now(), item.remote.modTime)
+	}
+	if len(failed) > 0 {
+		return newDownloadError(exitCodeTransferFailed, "sync fail, %d files failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return
+}
+
+// listRemoteTree lists files recursively using the json format directory listing of gmct web server.
+func (s *Tool) listRemoteTree(server *serverItem, remotePath, subPath string, args *DownloadArgs, files *[]*remoteEntry) (err error) {
+	_, _, client := s.getDownloadHTTPClient(server.auth, nil, args)
+	u := server.url.ResolveReference(&URL.URL{Path: remotePath + subPath, RawQuery: "format=json"})
+	body, code, _, err := client.Get(u.String(), time.Second*time.Duration(args.Timeout), nil, nil)
+	if err != nil {
+		return
+	}
+	if code != 200 {
+		return fmt.Errorf("list [%s] fail, response code: %d", u.Path, code)
+	}
+	var list []dirListItem
+	if err = json.Unmarshal(body, &list); err != nil {
+		return fmt.Errorf("list [%s] fail, the server may be an old version gmct which not support sync, error: %s", u.Path, err)
+	}
+	for _, v := range list {
+		p := subPath + v.Name
+		if v.IsDir {
+			if err = s.listRemoteTree(server, remotePath, p+"/", args, files); err != nil {
+				return
+			}
+			continue
+		}
+		*files = append(*files, &remoteEntry{path: p, size: v.Size, modTime: v.ModTime})
+	}
+	return
+}
+
+func (s *Tool) syncPlan(server *serverItem, remotePath string, remoteFiles []*remoteEntry, args *SyncArgs) (plan []*syncPlanItem, err error) {
+	remoteSet := map[string]bool{}
+	for _, r := range remoteFiles {
+		remoteSet[r.path] = true
+		localFile := filepath.Join(args.LocalDir, filepath.FromSlash(r.path))
+		st, e := os.Stat(localFile)
+		if e != nil || st.IsDir() {
+			plan = append(plan, &syncPlanItem{action: syncActionAdd, path: r.path, remote: r})
+			continue
+		}
+		changed := st.Size() != r.size
+		if !changed && args.Hash {
+			changed, err = s.isChecksumChanged(server, remotePath+r.path, localFile)
+			if err != nil {
+				return
+			}
+		} else if !changed {
+			changed = st.ModTime().Unix() != r.modTime.Unix()
+		}
+		if changed {
+			plan = append(plan, &syncPlanItem{action: syncActionUpdate, path: r.path, remote: r})
+		}
+	}
+	if args.Delete && gfile.IsDir(args.LocalDir) {
+		err = filepath.Walk(args.LocalDir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(args.LocalDir, p)
+			rel = filepath.ToSlash(rel)
+			if strings.HasSuffix(rel, partSuffix) || strings.HasSuffix(rel, partMetaSuffix) {
+				return nil
+			}
+			if !remoteSet[rel] {
+				plan = append(plan, &syncPlanItem{action: syncActionDelete, path: rel})
+			}
+			return nil
+		})
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].path < plan[j].path })
+	return
+}
+
+func (s *Tool) isChecksumChanged(server *serverItem, remoteFile, localFile string) (changed bool, err error) {
+	u := server.url.ResolveReference(&URL.URL{Path: path.Clean(remoteFile)})
+	info, err := newRangeDownloader(u.String(), server.auth, localFile, 1, true).stat()
+	if err != nil {
+		return
+	}
+	if info.checksum == "" {
+		return false, fmt.Errorf("the server not provide checksum of [%s]", remoteFile)
+	}
+	sum, err := checksum.SHA256sum(localFile)
+	if err != nil {
+		return
+	}
+	return !strings.EqualFold(sum, info.checksum), nil
+}
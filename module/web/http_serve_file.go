@@ -1,6 +1,7 @@
 package web
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -551,18 +552,29 @@ type anyDirs interface {
 	len() int
 	name(i int) string
 	isDir(i int) bool
+	info(i int) (fs.FileInfo, error)
 }
 type dirEntryDirs []fs.DirEntry
 
-func (d dirEntryDirs) len() int          { return len(d) }
-func (d dirEntryDirs) isDir(i int) bool  { return d[i].IsDir() }
-func (d dirEntryDirs) name(i int) string { return d[i].Name() }
+func (d dirEntryDirs) len() int                        { return len(d) }
+func (d dirEntryDirs) isDir(i int) bool                { return d[i].IsDir() }
+func (d dirEntryDirs) name(i int) string               { return d[i].Name() }
+func (d dirEntryDirs) info(i int) (fs.FileInfo, error) { return d[i].Info() }
 
 type fileInfoDirs []fs.FileInfo
 
-func (d fileInfoDirs) len() int          { return len(d) }
-func (d fileInfoDirs) isDir(i int) bool  { return d[i].IsDir() }
-func (d fileInfoDirs) name(i int) string { return d[i].Name() }
+func (d fileInfoDirs) len() int                        { return len(d) }
+func (d fileInfoDirs) isDir(i int) bool                { return d[i].IsDir() }
+func (d fileInfoDirs) name(i int) string               { return d[i].Name() }
+func (d fileInfoDirs) info(i int) (fs.FileInfo, error) { return d[i], nil }
+
+// dirListItem is an entry of the directory listing in json format.
+type dirListItem struct {
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
 
 func dirList(w http.ResponseWriter, r *http.Request, f http.File) {
 	// Prefer to use ReadDir instead of Readdir,
@@ -586,6 +598,11 @@ func dirList(w http.ResponseWriter, r *http.Request, f http.File) {
 	}
 	sort.Slice(dirs, func(i, j int) bool { return dirs.name(i) < dirs.name(j) })
 
+	if r.URL.Query().Get("format") == "json" {
+		dirListJSON(w, dirs)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	fmt.Fprintf(w, "<pre>\n")
 	for i, n := 0, dirs.len(); i < n; i++ {
@@ -602,6 +619,27 @@ func dirList(w http.ResponseWriter, r *http.Request, f http.File) {
 	fmt.Fprintf(w, "</pre>\n")
 }
 
+func dirListJSON(w http.ResponseWriter, dirs anyDirs) {
+	list := []dirListItem{}
+	for i, n := 0, dirs.len(); i < n; i++ {
+		item := dirListItem{Name: dirs.name(i), IsDir: dirs.isDir(i)}
+		if info, err := dirs.info(i); err == nil {
+			item.ModTime = info.ModTime()
+			if !item.IsDir {
+				item.Size = info.Size()
+			}
+		}
+		list = append(list, item)
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(b)
+}
+
 var htmlReplacer = strings.NewReplacer(
 	"&", "&amp;",
 	"<", "&lt;",
@@ -91,22 +91,37 @@ document.getElementById("upload").onclick=function () {document.forms["upload"].
 			sendError(w, r, err)
 			return
 		}
+		// dir is the sub directory of web root to save the files, it is sent by gmct upload.
+		dir := ""
+		if v := fs.Value["dir"]; len(v) > 0 {
+			if containsDotDot(v[0]) {
+				sendError(w, r, fmt.Errorf("invalid dir: %s", v[0]))
+				return
+			}
+			dir = filepath.Join("/", filepath.FromSlash(v[0]))
+		}
+		overwrite := len(fs.Value["overwrite"]) > 0 && fs.Value["overwrite"][0] == "true"
 		for _, f := range fs.File["file"] {
-			path := filepath.Join(args.RootDir, f.Filename)
+			name := filepath.Join(dir, f.Filename)
+			path := filepath.Join(args.RootDir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				sendError(w, r, err)
+				return
+			}
 			suffix := ""
-			if gfile.Exists(path) {
+			if gfile.Exists(path) && !overwrite {
 				suffix = "." + randID(6)
 				path += suffix
 			}
 			ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-			log.Printf("%s UPLOAD %s", ip, f.Filename+suffix)
+			log.Printf("%s UPLOAD %s", ip, filepath.ToSlash(name)+suffix)
 			srcFile, err := f.Open()
 			if err != nil {
 				sendError(w, r, err)
 				return
 			}
 			defer srcFile.Close()
-			dstFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+			dstFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 			if err != nil {
 				sendError(w, r, err)
 				return
@@ -165,6 +180,14 @@ document.getElementById("upload").onclick=function () {document.forms["upload"].
 		if id := args.ServerID; id != "" {
 			w.Header().Set(headerServerIDKey, id)
 		}
+		// checksum is only computed for HEAD requests, the downloader stat the file before downloading.
+		if r.Method == http.MethodHead && gfile.IsFile(reqPathAbs) {
+			if sum, err := fileChecksumCache.Get(reqPathAbs); err == nil {
+				w.Header().Set(headerChecksumKey, sum)
+			} else {
+				log.Printf("checksum error: %s, file: %s", err, reqPathAbs)
+			}
+		}
 		ServeFile(w, r, reqPathAbs, indexPage, args.RootDir)
 	}))
 	glog.Panic(http.ListenAndServe(args.Addr, nil))
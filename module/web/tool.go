@@ -1,10 +1,14 @@
 package web
 
 import (
+	"fmt"
+	glog "github.com/snail007/gmc/module/log"
 	"github.com/snail007/gmct/module/module"
 	"github.com/snail007/gmct/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"net"
+	"os"
 	"strings"
 )
 
@@ -59,38 +63,140 @@ func init() {
 					host = []string{a[0]}
 					file = a[1]
 				}
-				s.download(s.initDownload(&DownloadArgs{
-					Net:          util.Must(c.Flags().GetStringSlice("net")).StringSlice(),
-					Port:         util.Must(c.Flags().GetStringSlice("port")).StringSlice(),
-					File:         file,
-					Name:         util.Must(c.Flags().GetString("name")).String(),
-					MaxDeepLevel: util.Must(c.Flags().GetInt("deep")).Int(),
-					Host:         host,
-					Auth:         util.Must(c.Flags().GetString("auth")).String(),
-					ServerID:     id,
-					DownloadAll:  util.Must(c.Flags().GetBool("all")).Bool(),
-					Timeout:      util.Must(c.Flags().GetInt("timeout")).Int(),
-					DownloadDir:  util.Must(c.Flags().GetString("dir")).String(),
-				}))
+				overwrite, err := getOverwritePolicy(c)
+				if err != nil {
+					exitDownload(nil, nil, err)
+				}
+				args := s.initDownload(&DownloadArgs{
+					Net:             util.Must(c.Flags().GetStringSlice("net")).StringSlice(),
+					Port:            util.Must(c.Flags().GetStringSlice("port")).StringSlice(),
+					File:            file,
+					Name:            util.Must(c.Flags().GetString("name")).String(),
+					MaxDeepLevel:    util.Must(c.Flags().GetInt("deep")).Int(),
+					Host:            host,
+					Auth:            util.Must(c.Flags().GetString("auth")).String(),
+					ServerID:        id,
+					DownloadAll:     util.Must(c.Flags().GetBool("all")).Bool(),
+					Timeout:         util.Must(c.Flags().GetInt("timeout")).Int(),
+					DownloadDir:     util.Must(c.Flags().GetString("dir")).String(),
+					Threads:         util.Must(c.Flags().GetInt("threads")).Int(),
+					NoVerify:        util.Must(c.Flags().GetBool("no-verify")).Bool(),
+					Retry:           util.Must(c.Flags().GetInt("retry")).Int(),
+					Yes:             util.Must(c.Flags().GetBool("yes")).Bool(),
+					OverwritePolicy: overwrite,
+					JSON:            util.Must(c.Flags().GetBool("json")).Bool(),
+				})
+				if args.JSON {
+					glog.SetOutput(glog.NewLoggerWriter(os.Stderr))
+				}
+				result, err := s.download(args)
+				exitDownload(args, result, err)
 			},
 		}
-		downloadCMD.Flags().StringP("net", "n", "", "network to scan, format: 192.168.1.0")
-		downloadCMD.Flags().StringP("port", "p", "9669", "gmct tool http port")
+		bindDiscoverFlags(downloadCMD.PersistentFlags())
 		downloadCMD.Flags().StringP("file", "f", "*", "filename to download")
 		downloadCMD.Flags().StringP("name", "m", "", "rename download file to")
 		downloadCMD.Flags().IntP("deep", "d", 1, "max directory deep level to list server files, value 0: no limit")
-		downloadCMD.Flags().StringSliceP("host", "x", []string{}, "specify a domain or ip to download, example: 192.168.1.1 or 192.168.1.1:9090. \nyou can specify auth info, example: foo_user:foo_pass@192.168.1.2")
-		downloadCMD.Flags().StringP("auth", "a", "", "basic auth info, example: username:password")
-		downloadCMD.Flags().StringP("id", "i", "", "server id name to download files")
 		downloadCMD.Flags().Bool("all", false, "download all files matched")
-		downloadCMD.Flags().IntP("timeout", "t", 3, "timeout seconds to connect to server")
 		downloadCMD.Flags().StringP("dir", "c", "download_files", "path to download all files")
+		downloadCMD.PersistentFlags().Int("threads", 4, "count of parallel segments to download a file, 1: single connection")
+		downloadCMD.PersistentFlags().Bool("no-verify", false, "skip verifying the sha256 checksum provided by the server")
+		downloadCMD.PersistentFlags().Int("retry", 2, "retry count when the checksum of downloaded file mismatch")
+		downloadCMD.Flags().BoolP("yes", "y", false, "non-interactive, assume yes to all prompts: overwrite existing files and download all matched files")
+		downloadCMD.Flags().Bool("no-overwrite", false, "non-interactive, fail if the file already exists")
+		downloadCMD.Flags().Bool("skip-existing", false, "non-interactive, skip the file if it already exists")
+		downloadCMD.Flags().Bool("json", false, "non-interactive, print the downloaded files in json format to stdout")
+
+		syncCMD := &cobra.Command{
+			Use:  "sync <remote-path> <local-dir>",
+			Long: "download new or changed files of the remote directory to local directory",
+			Args: cobra.ExactArgs(2),
+			Run: func(c *cobra.Command, a []string) {
+				args := newDiscoverArgs(c)
+				args.Threads = util.Must(c.Flags().GetInt("threads")).Int()
+				args.NoVerify = util.Must(c.Flags().GetBool("no-verify")).Bool()
+				args.Retry = util.Must(c.Flags().GetInt("retry")).Int()
+				err := s.sync(&SyncArgs{
+					DownloadArgs: s.loadDownloadConfig(args),
+					RemotePath:   a[0],
+					LocalDir:     a[1],
+					Delete:       util.Must(c.Flags().GetBool("delete")).Bool(),
+					DryRun:       util.Must(c.Flags().GetBool("dry-run")).Bool(),
+					Hash:         util.Must(c.Flags().GetBool("hash")).Bool(),
+				})
+				exitDownload(args, nil, err)
+			},
+		}
+		syncCMD.Flags().Bool("delete", false, "delete local files which not exists on the server")
+		syncCMD.Flags().Bool("dry-run", false, "only print the sync plan, nothing will be transferred")
+		syncCMD.Flags().Bool("hash", false, "compare files by sha256 checksum instead of modification time")
+		downloadCMD.AddCommand(syncCMD)
+
+		uploadCMD := &cobra.Command{
+			Use:     "upload <files/dirs>",
+			Long:    "upload files or directories to gmct simple http server",
+			Aliases: []string{"ul"},
+			Args:    cobra.MinimumNArgs(1),
+			Run: func(c *cobra.Command, a []string) {
+				args := s.loadDownloadConfig(newDiscoverArgs(c))
+				err := s.upload(&UploadArgs{
+					DownloadArgs: args,
+					Files:        a,
+					UploadPath:   util.Must(c.Flags().GetString("path")).String(),
+					RemoteDir:    util.Must(c.Flags().GetString("to")).String(),
+					Overwrite:    util.Must(c.Flags().GetBool("overwrite")).Bool(),
+				})
+				exitDownload(args, nil, err)
+			},
+		}
+		bindDiscoverFlags(uploadCMD.Flags())
+		uploadCMD.Flags().StringP("path", "u", "", "upload url path of the server, it is the value of option -u of gmct web")
+		uploadCMD.Flags().StringP("to", "d", "", "remote directory relative to the web root to save files")
+		uploadCMD.Flags().Bool("overwrite", false, "overwrite the remote file if it exists, default a random suffix is added")
 
 		root.AddCommand(httpCMD)
 		root.AddCommand(downloadCMD)
+		root.AddCommand(uploadCMD)
 	})
 }
 
+// bindDiscoverFlags binds the flags used to discover gmct simple http server.
+func bindDiscoverFlags(flags *pflag.FlagSet) {
+	flags.StringSliceP("net", "n", []string{}, "network to scan, format: 192.168.1.0")
+	flags.StringSliceP("port", "p", []string{"9669"}, "gmct tool http port")
+	flags.StringSliceP("host", "x", []string{}, "specify a domain or ip to connect, example: 192.168.1.1 or 192.168.1.1:9090. \nyou can specify auth info, example: foo_user:foo_pass@192.168.1.2")
+	flags.StringP("auth", "a", "", "basic auth info, example: username:password")
+	flags.StringP("id", "i", "", "server id name to connect")
+	flags.IntP("timeout", "t", 3, "timeout seconds to connect to server")
+}
+
+func newDiscoverArgs(c *cobra.Command) *DownloadArgs {
+	return &DownloadArgs{
+		Net:      util.Must(c.Flags().GetStringSlice("net")).StringSlice(),
+		Port:     util.Must(c.Flags().GetStringSlice("port")).StringSlice(),
+		Host:     util.Must(c.Flags().GetStringSlice("host")).StringSlice(),
+		Auth:     util.Must(c.Flags().GetString("auth")).String(),
+		ServerID: util.Must(c.Flags().GetString("id")).String(),
+		Timeout:  util.Must(c.Flags().GetInt("timeout")).Int(),
+	}
+}
+
+func getOverwritePolicy(c *cobra.Command) (OverwritePolicy, error) {
+	noOverwrite := util.Must(c.Flags().GetBool("no-overwrite")).Bool()
+	skipExisting := util.Must(c.Flags().GetBool("skip-existing")).Bool()
+	switch {
+	case noOverwrite && skipExisting:
+		return OverwritePrompt, fmt.Errorf("option --no-overwrite and --skip-existing can not be used together")
+	case noOverwrite:
+		return OverwriteNo, nil
+	case skipExisting:
+		return OverwriteSkip, nil
+	case util.Must(c.Flags().GetBool("yes")).Bool():
+		return OverwriteYes, nil
+	}
+	return OverwritePrompt, nil
+}
+
 type Tool struct {
 }
 
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	URL "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	glog "github.com/snail007/gmc/module/log"
+)
+
+type UploadArgs struct {
+	*DownloadArgs
+	Files      []string
+	UploadPath string
+	RemoteDir  string
+	Overwrite  bool
+}
+
+// uploadItem is a local file to upload, dir is the remote directory relative to the web root.
+type uploadItem struct {
+	file string
+	dir  string
+	size int64
+}
+
+func (s *Tool) upload(args *UploadArgs) (err error) {
+	uploadPath := args.UploadPath
+	if uploadPath == "" {
+		if v := s.getDownloadConfig("upload", args.DownloadArgs); v != nil {
+			uploadPath = v.(string)
+		}
+	}
+	uploadPath = strings.Trim(uploadPath, "/")
+	if uploadPath == "" {
+		return fmt.Errorf("upload path required, it is the value of option -u of gmct web, use option: --path xxx")
+	}
+	items, err := s.getUploadItems(args.Files, strings.Trim(args.RemoteDir, "/"))
+	if err != nil {
+		return
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no files found to upload")
+	}
+	server, err := s.getServerURL(args.DownloadArgs)
+	if err != nil {
+		return
+	}
+	uploadURL := server.url.ResolveReference(&URL.URL{Path: "/" + uploadPath})
+	var failed []string
+	for i, item := range items {
+		fmt.Println("uploading: " + item.file)
+		e := s.uploadFile(uploadURL.String(), server.auth, item, args.Overwrite, fmt.Sprintf("(%d/%d)", i+1, len(items)))
+		if e != nil {
+			glog.Warnf("upload error: %s", e)
+			failed = append(failed, item.file)
+		}
+	}
+	if len(failed) > 0 {
+		return newDownloadError(exitCodeTransferFailed, "upload fail, %d files failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	glog.Info("upload SUCCESS")
+	return
+}
+
+// getUploadItems expands the files and directories, a directory is uploaded with its name as the top directory.
+func (s *Tool) getUploadItems(files []string, remoteDir string) (items []*uploadItem, err error) {
+	for _, f := range files {
+		st, e := os.Stat(f)
+		if e != nil {
+			return nil, e
+		}
+		if !st.IsDir() {
+			items = append(items, &uploadItem{file: f, dir: remoteDir, size: st.Size()})
+			continue
+		}
+		base := filepath.Base(filepath.Clean(f))
+		err = filepath.Walk(f, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(f, filepath.Dir(p))
+			items = append(items, &uploadItem{
+				file: p,
+				dir:  path.Join(remoteDir, base, filepath.ToSlash(rel)),
+				size: info.Size(),
+			})
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *Tool) uploadFile(uploadURL string, auth []string, item *uploadItem, overwrite bool, desc string) (err error) {
+	f, err := os.Open(item.file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		var e error
+		defer func() {
+			if e != nil {
+				pw.CloseWithError(e)
+			} else {
+				pw.Close()
+			}
+		}()
+		if e = mw.WriteField("dir", item.dir); e != nil {
+			return
+		}
+		if e = mw.WriteField("overwrite", fmt.Sprintf("%v", overwrite)); e != nil {
+			return
+		}
+		part, e := mw.CreateFormFile("file", filepath.Base(item.file))
+		if e != nil {
+			return
+		}
+		bar := newProgressBar(item.size, desc)
+		if _, e = io.Copy(io.MultiWriter(part, bar), f); e != nil {
+			return
+		}
+		e = mw.Close()
+	}()
+	defer pr.Close()
+	req, err := http.NewRequest(http.MethodPost, uploadURL, pr)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if len(auth) == 2 {
+		req.SetBasicAuth(auth[0], auth[1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("response status: %s, %s", resp.Status, string(b))
+	}
+	return
+}
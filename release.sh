@@ -10,6 +10,7 @@ RELEASE_DIR="release-$VERSION"
 
 cat >cmd/gmct/version.go <<EOF
 package main
+
 var version = "$VERSION_RAW"
 EOF
 
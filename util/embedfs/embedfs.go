@@ -0,0 +1,49 @@
+// Package embedfs loads the templates and serves the static files embedded by go:embed,
+// it is used by the web ui of commands, such as the pprof analyze server.
+package embedfs
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	gcore "github.com/snail007/gmc/core"
+	gtemplate "github.com/snail007/gmc/http/template"
+	gctx "github.com/snail007/gmc/module/ctx"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParseTemplate binds the .html files in the tpl directory of tplFiles to tpl, the template name is the file path
+// without .html extension, and trimPrefix is trimmed from it, such as: "tpl/".
+func ParseTemplate(tpl *gtemplate.Template, tplFiles embed.FS, trimPrefix string) {
+	bindData := map[string]string{}
+	fs.WalkDir(tplFiles, "tpl", func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() && filepath.Ext(d.Name()) == ".html" {
+			b, _ := tplFiles.ReadFile(path)
+			key := strings.TrimSuffix(strings.TrimPrefix(path, trimPrefix), ".html")
+			bindData[key] = base64.StdEncoding.EncodeToString(b)
+		}
+		return nil
+	})
+	tpl.SetBinBase64(bindData)
+}
+
+// Serve serves the staticFiles on the url path pathPrefix.
+func Serve(router gcore.HTTPRouter, staticFiles embed.FS, pathPrefix string) {
+	pathPrefix = strings.TrimSuffix(pathPrefix, "/")
+	bindPath := pathPrefix + "/*filepath"
+	router.HandlerFuncAny(bindPath, func(w http.ResponseWriter, r *http.Request) {
+		ctx := gctx.NewCtxWithHTTP(w, r)
+		path := strings.TrimPrefix(ctx.Request().URL.Path, pathPrefix+"/")
+		b, err := staticFiles.ReadFile(path)
+		if err != nil {
+			ctx.Status(500)
+			ctx.Write(err)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(path), time.Time{}, bytes.NewReader(b))
+	})
+}
@@ -0,0 +1,170 @@
+// Package filewatch finds the changed files of directories by polling, the files are filtered by
+// extensions, include files, exclude files and exclude directories, hidden files and directories are ignored.
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Matcher struct {
+	Dirs     []string
+	OnlyExts map[string]bool
+	// AllExts matches the files of all extensions if OnlyExts is empty, otherwise no file matched by extension.
+	AllExts      bool
+	IncludeFiles map[string]bool
+	ExcludeFiles map[string]bool
+	ExcludeDirs  map[string]bool
+}
+
+// NewMatcher creates a Matcher, relative paths of includeFiles, excludeFiles and excludeDirs
+// are relative to each of the dirs.
+func NewMatcher(dirs, exts, includeFiles, excludeFiles, excludeDirs []string) *Matcher {
+	m := &Matcher{
+		OnlyExts:     map[string]bool{},
+		IncludeFiles: map[string]bool{},
+		ExcludeFiles: map[string]bool{},
+		ExcludeDirs:  map[string]bool{},
+	}
+	for _, v := range exts {
+		m.OnlyExts[v] = true
+	}
+	for _, v := range dirs {
+		v, _ = filepath.Abs(v)
+		m.Dirs = append(m.Dirs, v)
+	}
+	for arr, cfgarr := range map[*map[string]bool][]string{
+		&m.ExcludeFiles: excludeFiles,
+		&m.ExcludeDirs:  excludeDirs,
+		&m.IncludeFiles: includeFiles,
+	} {
+		for _, v := range cfgarr {
+			if filepath.IsAbs(v) {
+				(*arr)[v] = true
+			} else {
+				for _, d := range m.Dirs {
+					(*arr)[filepath.Join(d, v)] = true
+				}
+			}
+		}
+	}
+	return m
+}
+
+// Files returns all matched files in the dirs.
+func (s *Matcher) Files() (names []string) {
+	for _, d := range s.Dirs {
+		s.tree(d, &names)
+	}
+	return
+}
+
+func (s *Matcher) tree(folder string, names *[]string) (err error) {
+	name := filepath.Base(folder)
+	if strings.HasPrefix(name, ".") {
+		return
+	}
+	f, err := os.Open(folder)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	finfo, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if !finfo.IsDir() {
+		return
+	}
+	files, err := filepath.Glob(folder + "/*")
+	if err != nil {
+		return
+	}
+	var file *os.File
+	for _, v := range files {
+		if file != nil {
+			file.Close()
+		}
+		file, err = os.Open(v)
+		if err != nil {
+			return
+		}
+		fileInfo, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		n := filepath.Base(v)
+		if strings.HasPrefix(n, ".") {
+			continue
+		}
+		if fileInfo.IsDir() {
+			if s.ExcludeDirs[v] {
+				continue
+			}
+			err = s.tree(v, names)
+			if err != nil {
+				return err
+			}
+		} else {
+			if s.ExcludeFiles[v] {
+				continue
+			}
+			if !(s.AllExts && len(s.OnlyExts) == 0) && !s.OnlyExts[filepath.Ext(n)] && !s.IncludeFiles[v] {
+				continue
+			}
+			*names = append(*names, v)
+		}
+	}
+	if file != nil {
+		file.Close()
+	}
+	return
+}
+
+// Scanner compares the matched files with the last scan, to find the added, deleted and modified files.
+type Scanner struct {
+	// NanoTime compares the modification time in nanoseconds, otherwise in seconds.
+	NanoTime bool
+	matcher  *Matcher
+	list     map[string]int64
+}
+
+func NewScanner(matcher *Matcher) *Scanner {
+	return &Scanner{matcher: matcher, list: map[string]int64{}}
+}
+
+// Changed scans the files and returns true if any file is added, deleted or modified since last scan,
+// the first scan always returns true if any file matched.
+func (s *Scanner) Changed() (changed bool) {
+	names := s.matcher.Files()
+	var newList = map[string]bool{}
+	for _, v := range names {
+		newList[v] = true
+	}
+
+	// deleted files found?
+	for k := range s.list {
+		if !newList[k] {
+			changed = true
+			delete(s.list, k)
+		}
+	}
+
+	// added and changed will be monitor here
+	for _, v := range names {
+		st, err := os.Stat(v)
+		if err != nil {
+			continue
+		}
+		t0 := st.ModTime().Unix()
+		if s.NanoTime {
+			t0 = st.ModTime().UnixNano()
+		}
+		if t, ok := s.list[v]; !ok || t0 != t {
+			changed = true
+		}
+		s.list[v] = t0
+	}
+	return
+}